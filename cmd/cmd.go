@@ -2,17 +2,26 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+	"github.com/diasjorge/dynamokv/formatter"
 	"github.com/diasjorge/dynamokv/models"
+	"github.com/diasjorge/dynamokv/serializer"
+	"github.com/diasjorge/dynamokv/table"
 )
 
 var export, deserialize bool
-var endpointURL, region, profile string
+var endpointURL, region, profile, format string
+var namespaces []string
 
 // commandError is an error used to signal different error situations in command handling.
 type commandError struct {
@@ -32,13 +41,36 @@ func newUserError(a ...interface{}) commandError {
 	return commandError{s: fmt.Sprintln(a...), userError: true}
 }
 
+// conflictExitCode is the exit status when a conditional write fails because
+// the stored version changed.
+const conflictExitCode = 3
+
+// exitError is used to make dynamokv exit with a specific status code.
+type exitError struct {
+	code int
+}
+
+func (e exitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+// Session holds the AWS clients used by commands. The clients are interfaces
+// so they can be replaced by the fakes in the fake package.
 type Session struct {
 	Session  *session.Session
-	DynamoDB *dynamodb.DynamoDB
-	KMS      *kms.KMS
+	DynamoDB dynamodbiface.DynamoDBAPI
+	KMS      kmsiface.KMSAPI
+	STS      stsiface.STSAPI
+	// Namespaces scope the keys read and written. Only fetch reads more
+	// than one, later ones overriding earlier ones.
+	Namespaces []string
 }
 
-func newSession(region, profile, endpointURL string) *Session {
+// newSession returns the Session used by commands. Tests replace it to run
+// commands against the fakes.
+var newSession = newAWSSession
+
+func newAWSSession(region, profile, endpointURL string, namespaces []string) *Session {
 	config := aws.NewConfig().WithRegion(region)
 	sess := session.Must(session.NewSessionWithOptions(session.Options{
 		Config:  *config,
@@ -49,21 +81,113 @@ func newSession(region, profile, endpointURL string) *Session {
 
 	kmsSvc := kms.New(sess)
 
+	stsSvc := sts.New(sess)
+
 	return &Session{
-		Session:  sess,
-		DynamoDB: dynamodbSvc,
-		KMS:      kmsSvc,
+		Session:    sess,
+		DynamoDB:   dynamodbSvc,
+		KMS:        kmsSvc,
+		STS:        stsSvc,
+		Namespaces: namespaces,
 	}
 }
 
-func printItem(item *models.Item, export bool) {
-	format := "%s='%s'\n"
-	if export {
-		format = "export " + format
+// namespace returns the namespace commands read and write, the last one
+// given, or the default namespace.
+func (session *Session) namespace() string {
+	if len(session.Namespaces) == 0 {
+		return ""
 	}
-	fmt.Printf(format, item.Key, escape(item.Value))
+	return session.Namespaces[len(session.Namespaces)-1]
+}
+
+// withNamespace returns a copy of session working on a single namespace.
+func (session *Session) withNamespace(namespace string) *Session {
+	layer := *session
+	layer.Namespaces = []string{namespace}
+	return &layer
 }
 
-func escape(value string) string {
-	return strings.Replace(value, "'", "'\\''", -1)
+// checkKey rejects keys containing the models.NamespaceSeparator, which
+// would be stored in another namespace.
+func checkKey(key string) error {
+	if strings.Contains(key, models.NamespaceSeparator) {
+		return fmt.Errorf("Invalid key \"%s\": keys can't contain \"%s\"", key, models.NamespaceSeparator)
+	}
+	return nil
+}
+
+// callerIdentity returns the ARN of the AWS identity running the command, or
+// an empty string if it cannot be determined.
+func callerIdentity(session *Session) string {
+	if session.STS == nil {
+		return ""
+	}
+	resp, err := session.STS.GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	if err != nil {
+		return ""
+	}
+	return aws.StringValue(resp.Arn)
+}
+
+// openTable returns the table scoped to the namespace of session.
+func openTable(session *Session, tableName string) *table.Table {
+	table := table.NewTable(session.DynamoDB, tableName)
+	table.Namespace = session.namespace()
+	return table
+}
+
+// newTable returns the table attributing writes to the caller identity.
+func newTable(session *Session, tableName string) *table.Table {
+	table := openTable(session, tableName)
+	table.Author = callerIdentity(session)
+	return table
+}
+
+// createTable creates table if it does not exist, unless noCreate is set, in
+// which case a missing table is an error.
+func createTable(table *table.Table, noCreate bool) error {
+	if !noCreate {
+		return table.Create()
+	}
+	return checkTable(table)
+}
+
+// checkTable returns an error if table or its history table does not exist.
+func checkTable(table *table.Table) error {
+	exists, err := table.Exists()
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("Table %s does not exist. Create it with dynamokv table create", *table.Name)
+	}
+	exists, err = table.History().Exists()
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("Table %s has no history table. Run dynamokv table migrate %s", *table.Name, *table.Name)
+	}
+	return nil
+}
+
+// newSerializerContext returns a serializer context for the items in the
+// namespace of session.
+func newSerializerContext(session *Session, tableName string) *serializer.Context {
+	ctx := serializer.NewContext(session.KMS, tableName)
+	ctx.Namespace = session.namespace()
+	return ctx
+}
+
+// printItems writes items to stdout in the given format.
+func printItems(items []*models.Item, format string, export, deserialize bool) error {
+	if format == "yaml" {
+		return formatter.NewYAML(!deserialize).Format(os.Stdout, items)
+	}
+	formatter, err := formatter.New(format, export)
+	if err != nil {
+		return err
+	}
+	return formatter.Format(os.Stdout, items)
 }
@@ -0,0 +1,185 @@
+// Copyright © 2017 Jorge Dias <jorge@mrdias.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// deleteCmd represents the delete command
+var deleteCmd = &cobra.Command{
+	Use:   "delete TABLENAME [KEY...]",
+	Short: "Delete Keys",
+	Long: `Delete Keys from a DynamoDB table.
+KEY may be a glob pattern such as "DB_*", where "*" and "?" also match "/".
+Keys can also be selected with --prefix.`,
+	RunE: deleteParse,
+}
+
+var deletePrefix string
+var assumeYes bool
+
+func init() {
+	RootCmd.AddCommand(deleteCmd)
+	deleteCmd.Flags().StringVarP(&deletePrefix, "prefix", "", "", "Delete all keys starting with prefix")
+	deleteCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Do not ask for confirmation")
+}
+
+func deleteParse(cmd *cobra.Command, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("Invalid arguments\n%s", cmd.UsageString())
+	}
+	if len(args) < 2 && deletePrefix == "" {
+		return fmt.Errorf("KEY or --prefix required\n%s", cmd.UsageString())
+	}
+
+	tableName, patterns := args[0], args[1:]
+
+	session := newSession(region, profile, endpointURL, namespaces)
+
+	return deleteKeys(session, tableName, patterns, deletePrefix, assumeYes)
+}
+
+func deleteKeys(session *Session, tableName string, patterns []string, prefix string, assumeYes bool) error {
+	table := openTable(session, tableName)
+
+	parsedItems, err := table.Read()
+	if err != nil {
+		return err
+	}
+	existing := []string{}
+	for _, parsedItem := range parsedItems {
+		existing = append(existing, parsedItem.Key)
+	}
+
+	keys, missing, err := selectKeys(existing, patterns, prefix)
+	if err != nil {
+		return err
+	}
+
+	if len(keys) > 0 {
+		if !assumeYes && !confirm(fmt.Sprintf("The following keys will be deleted from %s:\n  %s\n", tableName, strings.Join(keys, "\n  "))) {
+			return errors.New("Aborted")
+		}
+		if err := table.BatchDelete(keys); err != nil {
+			return err
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("Keys not found: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// selectKeys returns the existing keys matching any of the patterns or the
+// prefix, and the patterns and prefix that matched no key.
+func selectKeys(existing, patterns []string, prefix string) ([]string, []string, error) {
+	selected := map[string]bool{}
+	missing := []string{}
+
+	for _, pattern := range patterns {
+		glob, err := globPattern(pattern)
+		if err != nil {
+			return nil, nil, err
+		}
+		found := false
+		for _, key := range existing {
+			if glob.MatchString(key) {
+				selected[key] = true
+				found = true
+			}
+		}
+		if !found {
+			missing = append(missing, pattern)
+		}
+	}
+
+	if prefix != "" {
+		found := false
+		for _, key := range existing {
+			if strings.HasPrefix(key, prefix) {
+				selected[key] = true
+				found = true
+			}
+		}
+		if !found {
+			missing = append(missing, "--prefix "+prefix)
+		}
+	}
+
+	keys := []string{}
+	for key := range selected {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, missing, nil
+}
+
+// globPattern compiles a glob pattern into a regular expression. Unlike
+// path.Match, "*" and "?" also match "/", so "app*" selects "app/db".
+func globPattern(pattern string) (*regexp.Regexp, error) {
+	expr := "(?s)^"
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			expr += ".*"
+		case '?':
+			expr += "."
+		case '\\':
+			if i+1 == len(pattern) {
+				return nil, fmt.Errorf("Invalid pattern %s", pattern)
+			}
+			i++
+			expr += regexp.QuoteMeta(pattern[i : i+1])
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("Invalid pattern %s", pattern)
+			}
+			expr += "[" + pattern[i+1:i+1+end] + "]"
+			i += end + 1
+		default:
+			expr += regexp.QuoteMeta(string(c))
+		}
+	}
+	glob, err := regexp.Compile(expr + "$")
+	if err != nil {
+		return nil, fmt.Errorf("Invalid pattern %s", pattern)
+	}
+	return glob, nil
+}
+
+// confirm prints message to stderr and reads a yes/no answer from stdin.
+func confirm(message string) bool {
+	fmt.Fprint(os.Stderr, message, "Continue? [y/N] ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
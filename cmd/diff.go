@@ -0,0 +1,197 @@
+// Copyright © 2017 Jorge Dias <jorge@mrdias.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/diasjorge/dynamokv/models"
+	"github.com/diasjorge/dynamokv/parser"
+	"github.com/diasjorge/dynamokv/serializer"
+	"github.com/spf13/cobra"
+)
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff TABLENAME (CONFIGFILE | --table OTHER_TABLENAME)",
+	Short: "Show differences between a table and a configuration file or another table",
+	Long: `Show the changes that storing a configuration file, or copying another table,
+would make to a DynamoDB table. Keys are reported as added (+) or changed (~).
+Keys missing from the file or other table are reported as kept (?), or as removed (-)
+with --prune, as store --prune would delete them. All of them count for --exit-code.
+Values are compared after deserialization. Changed values are masked unless --show-values
+is given, and encrypted values are only printed with --show-secrets.`,
+	RunE: diffParse,
+}
+
+var otherTable string
+var showValues, showSecrets, exitCodeOnDiff, diffPrune bool
+
+func init() {
+	RootCmd.AddCommand(diffCmd)
+	diffCmd.Flags().StringVarP(&otherTable, "table", "", "", "Compare with another table instead of a configuration file")
+	diffCmd.Flags().BoolVarP(&showValues, "show-values", "", false, "Show changed values that are not encrypted")
+	diffCmd.Flags().BoolVarP(&showSecrets, "show-secrets", "", false, "Show changed values, including encrypted ones")
+	diffCmd.Flags().BoolVarP(&diffPrune, "prune", "", false, "Report keys missing from the file or other table as removed")
+	diffCmd.Flags().BoolVarP(&exitCodeOnDiff, "exit-code", "", false, "Exit with status 1 if there are differences")
+}
+
+func diffParse(cmd *cobra.Command, args []string) error {
+	if len(args) == 1 && otherTable == "" || len(args) == 2 && otherTable != "" || len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("TABLENAME and either CONFIGFILE or --table required\n%s", cmd.UsageString())
+	}
+
+	tableName, configFile := args[0], ""
+	if len(args) == 2 {
+		configFile = args[1]
+	}
+
+	session := newSession(region, profile, endpointURL, namespaces)
+
+	changes, err := diff(session, tableName, configFile, otherTable)
+	if err != nil {
+		return err
+	}
+	printChanges(changes, diffPrune, showValues || showSecrets, showSecrets)
+
+	if exitCodeOnDiff && len(changes) > 0 {
+		cmd.SilenceErrors = true
+		return exitError{code: 1}
+	}
+	return nil
+}
+
+// itemChange describes how a key differs between the current and the desired
+// items. Current is nil for added keys and Desired is nil for removed keys.
+type itemChange struct {
+	Key     string
+	Current *models.Item
+	Desired *models.Item
+}
+
+// diff returns the changes that storing configFile, or copying otherTableName,
+// would make to tableName. Keys missing from the desired items are returned as
+// removals, which store only makes with --prune.
+func diff(session *Session, tableName, configFile, otherTableName string) ([]*itemChange, error) {
+	current, err := readItems(session, tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	var desired []*models.Item
+	if otherTableName != "" {
+		desired, err = readItems(session, otherTableName)
+	} else {
+		desired, err = parseItems(session, tableName, configFile)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return diffItems(current, desired), nil
+}
+
+// readItems returns the deserialized items of a table.
+func readItems(session *Session, tableName string) ([]*models.Item, error) {
+	parsedItems, err := openTable(session, tableName).Read()
+	if err != nil {
+		return nil, err
+	}
+	return serializer.DeserializeItems(newSerializerContext(session, tableName), parsedItems, true)
+}
+
+// parseItems returns the items of a configuration file with plaintext values,
+// deserializing values that are stored serialized in the file.
+func parseItems(session *Session, tableName, configFile string) ([]*models.Item, error) {
+	parsedItems, err := parser.Parse(configFile)
+	if err != nil {
+		return nil, err
+	}
+	ctx := newSerializerContext(session, tableName)
+	items := []*models.Item{}
+	for _, parsedItem := range parsedItems {
+		item, err := serializer.DeserializeItem(ctx, parsedItem, parsedItem.Value.Serialized)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func diffItems(current, desired []*models.Item) []*itemChange {
+	currentByKey := map[string]*models.Item{}
+	for _, item := range current {
+		currentByKey[item.Key] = item
+	}
+	desiredByKey := map[string]*models.Item{}
+	for _, item := range desired {
+		desiredByKey[item.Key] = item
+	}
+
+	changes := []*itemChange{}
+	for key, desiredItem := range desiredByKey {
+		currentItem, ok := currentByKey[key]
+		if !ok || currentItem.Value != desiredItem.Value || currentItem.Serialization != desiredItem.Serialization {
+			changes = append(changes, &itemChange{Key: key, Current: currentItem, Desired: desiredItem})
+		}
+	}
+	for key, currentItem := range currentByKey {
+		if _, ok := desiredByKey[key]; !ok {
+			changes = append(changes, &itemChange{Key: key, Current: currentItem})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
+}
+
+// printChanges prints one line per change. Removals are only labeled as such
+// with prune, otherwise the keys are reported as kept.
+func printChanges(changes []*itemChange, prune, showValues, showSecrets bool) {
+	for _, change := range changes {
+		switch {
+		case change.Current == nil:
+			fmt.Printf("+ %s\n", change.Key)
+		case change.Desired == nil && prune:
+			fmt.Printf("- %s\n", change.Key)
+		case change.Desired == nil:
+			fmt.Printf("? %s (kept without --prune)\n", change.Key)
+		default:
+			line := "~ " + change.Key
+			if change.Current.Serialization != change.Desired.Serialization {
+				line += fmt.Sprintf(" (serialization %s => %s)", change.Current.Serialization, change.Desired.Serialization)
+			}
+			if change.Current.Value != change.Desired.Value {
+				line += ": " + maskValue(change.Current, showValues, showSecrets) + " => " + maskValue(change.Desired, showValues, showSecrets)
+			}
+			fmt.Println(line)
+		}
+	}
+}
+
+func maskValue(item *models.Item, showValues, showSecrets bool) string {
+	encrypted := serializer.Encrypted(&models.Serialization{Type: item.Serialization})
+	if showSecrets || showValues && !encrypted {
+		return fmt.Sprintf("%q", item.Value)
+	}
+	return "*****"
+}
@@ -0,0 +1,91 @@
+// Copyright © 2017 Jorge Dias <jorge@mrdias.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/diasjorge/dynamokv/formatter"
+	"github.com/diasjorge/dynamokv/serializer"
+	"github.com/spf13/cobra"
+)
+
+// dumpCmd represents the dump command
+var dumpCmd = &cobra.Command{
+	Use:   "dump TABLENAME [FILE]",
+	Short: "Export a table as a configuration file",
+	Long: `Export all Key Value pairs from a DynamoDB table in the configuration file format used by store.
+By default values are written as stored, so encrypted values stay encrypted and
+storing the file reproduces the same items. With --plaintext values are
+deserialized and storing the file serializes them again.`,
+	RunE: dumpParse,
+}
+
+var plaintext bool
+
+func init() {
+	RootCmd.AddCommand(dumpCmd)
+	dumpCmd.Flags().BoolVarP(&plaintext, "plaintext", "", false, "Write deserialized values")
+}
+
+func dumpParse(cmd *cobra.Command, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return errors.New("TABLENAME required")
+	}
+
+	tableName, outputFile := args[0], ""
+	if len(args) == 2 {
+		outputFile = args[1]
+	}
+
+	session := newSession(region, profile, endpointURL, namespaces)
+
+	return dump(session, tableName, outputFile, plaintext)
+}
+
+func dump(session *Session, tableName, outputFile string, plaintext bool) error {
+	table := openTable(session, tableName)
+
+	parsedItems, err := table.Read()
+	if err != nil {
+		return err
+	}
+
+	items, err := serializer.DeserializeItems(newSerializerContext(session, tableName), parsedItems, plaintext)
+	if err != nil {
+		return err
+	}
+
+	var output bytes.Buffer
+	if err := formatter.NewYAML(!plaintext).Format(&output, items); err != nil {
+		return err
+	}
+
+	if outputFile != "" {
+		return ioutil.WriteFile(outputFile, output.Bytes(), 0600)
+	}
+	_, err = fmt.Fprint(os.Stdout, output.String())
+	return err
+}
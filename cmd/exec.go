@@ -0,0 +1,162 @@
+// Copyright © 2017 Jorge Dias <jorge@mrdias.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/diasjorge/dynamokv/models"
+	"github.com/diasjorge/dynamokv/serializer"
+	"github.com/spf13/cobra"
+)
+
+// execCmd represents the exec command
+var execCmd = &cobra.Command{
+	Use:   "exec TABLENAME -- COMMAND [ARGS...]",
+	Short: "Run a command with Key Value pairs in its environment",
+	Long: `Run a command with all Key Value pairs from a DynamoDB table loaded as environment variables.
+Signals received by dynamokv are forwarded to the command and its exit code is propagated.`,
+	RunE: execParse,
+}
+
+var keepExisting, cleanEnv bool
+
+// forwardedSignals are relayed to the child process while it runs.
+var forwardedSignals = []os.Signal{
+	syscall.SIGINT,
+	syscall.SIGTERM,
+	syscall.SIGHUP,
+	syscall.SIGQUIT,
+}
+
+func init() {
+	RootCmd.AddCommand(execCmd)
+	execCmd.Flags().SetInterspersed(false)
+	execCmd.Flags().BoolVarP(&keepExisting, "keep-existing", "", false, "Do not override variables already present in the environment")
+	execCmd.Flags().BoolVarP(&cleanEnv, "clean-env", "", false, "Start the command with only the table variables in its environment")
+}
+
+func execParse(cmd *cobra.Command, args []string) error {
+	if len(args) < 2 {
+		return errors.New("TABLENAME and COMMAND required")
+	}
+
+	tableName, command := args[0], args[1:]
+	// Flag parsing stops at TABLENAME, so the "--" separating COMMAND is
+	// passed as an argument.
+	if command[0] == "--" {
+		command = command[1:]
+	}
+	if len(command) == 0 {
+		return errors.New("TABLENAME and COMMAND required")
+	}
+
+	session := newSession(region, profile, endpointURL, namespaces)
+
+	err := execCommand(session, tableName, command, keepExisting, cleanEnv)
+	if _, ok := err.(exitError); ok {
+		cmd.SilenceErrors = true
+	}
+	return err
+}
+
+func execCommand(session *Session, tableName string, command []string, keepExisting, cleanEnv bool) error {
+	table := openTable(session, tableName)
+
+	parsedItems, err := table.Read()
+	if err != nil {
+		return err
+	}
+
+	items, err := serializer.DeserializeItems(newSerializerContext(session, tableName), parsedItems, true)
+	if err != nil {
+		return err
+	}
+
+	environ := os.Environ()
+	if cleanEnv {
+		environ = []string{}
+	}
+
+	child := exec.Command(command[0], command[1:]...)
+	child.Env = mergeEnv(environ, items, !keepExisting)
+	child.Stdin = os.Stdin
+	child.Stdout = os.Stdout
+	child.Stderr = os.Stderr
+
+	if err := child.Start(); err != nil {
+		return err
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, forwardedSignals...)
+	go func() {
+		for sig := range signals {
+			child.Process.Signal(sig)
+		}
+	}()
+
+	err = child.Wait()
+	signal.Stop(signals)
+	close(signals)
+
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return exitError{code: exitCode(exitErr)}
+	}
+	return err
+}
+
+// mergeEnv adds items to environ as KEY=value entries. Variables already
+// present in environ are replaced only when override is true.
+func mergeEnv(environ []string, items []*models.Item, override bool) []string {
+	env := make([]string, len(environ))
+	copy(env, environ)
+
+	index := map[string]int{}
+	for i, entry := range env {
+		name := strings.SplitN(entry, "=", 2)[0]
+		index[name] = i
+	}
+
+	for _, item := range items {
+		entry := item.Key + "=" + item.Value
+		i, ok := index[item.Key]
+		if !ok {
+			index[item.Key] = len(env)
+			env = append(env, entry)
+		} else if override {
+			env[i] = entry
+		}
+	}
+	return env
+}
+
+func exitCode(err *exec.ExitError) int {
+	if status, ok := err.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return err.ExitCode()
+}
@@ -22,9 +22,12 @@ package cmd
 
 import (
 	"errors"
+	"sort"
+	"strings"
 
+	"github.com/diasjorge/dynamokv/formatter"
+	"github.com/diasjorge/dynamokv/models"
 	"github.com/diasjorge/dynamokv/serializer"
-	"github.com/diasjorge/dynamokv/table"
 	"github.com/spf13/cobra"
 )
 
@@ -39,6 +42,7 @@ var fetchCmd = &cobra.Command{
 func init() {
 	RootCmd.AddCommand(fetchCmd)
 	fetchCmd.Flags().BoolVarP(&export, "export", "", false, "Export variables")
+	fetchCmd.Flags().StringVarP(&format, "format", "f", "shell", "Output format: "+strings.Join(formatter.Formats, ", "))
 	fetchCmd.Flags().BoolVarP(&deserialize, "deserialize", "", true, "Deserialize items")
 }
 
@@ -49,27 +53,46 @@ func fetchParse(cmd *cobra.Command, args []string) error {
 
 	tableName := args[0]
 
-	session := newSession(region, profile, endpointURL)
+	session := newSession(region, profile, endpointURL, namespaces)
 
-	return fetch(session, tableName, export, deserialize)
+	return fetch(session, tableName, format, export, deserialize)
 }
 
-func fetch(session *Session, tableName string, export, deserialize bool) error {
-	table := table.NewTable(session.DynamoDB, tableName)
-
-	parsedItems, err := table.Read()
-	if err != nil {
-		return err
+// fetch prints the items of every namespace of session, keys in later
+// namespaces overriding the same keys in earlier ones.
+func fetch(session *Session, tableName, format string, export, deserialize bool) error {
+	layers := session.Namespaces
+	if len(layers) == 0 {
+		layers = []string{""}
 	}
 
-	items, err := serializer.DeserializeItems(session.KMS, parsedItems, deserialize)
-	if err != nil {
-		return err
+	merged := map[string]*models.Item{}
+	for _, namespace := range layers {
+		layer := session.withNamespace(namespace)
+
+		parsedItems, err := openTable(layer, tableName).Read()
+		if err != nil {
+			return err
+		}
+
+		items, err := serializer.DeserializeItems(newSerializerContext(layer, tableName), parsedItems, deserialize)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			merged[item.Key] = item
+		}
 	}
 
-	for _, item := range items {
-		printItem(item, export)
+	keys := []string{}
+	for key := range merged {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	items := []*models.Item{}
+	for _, key := range keys {
+		items = append(items, merged[key])
 	}
 
-	return nil
+	return printItems(items, format, export, deserialize)
 }
@@ -22,9 +22,11 @@ package cmd
 
 import (
 	"errors"
+	"strings"
 
+	"github.com/diasjorge/dynamokv/formatter"
+	"github.com/diasjorge/dynamokv/models"
 	"github.com/diasjorge/dynamokv/serializer"
-	"github.com/diasjorge/dynamokv/table"
 	"github.com/spf13/cobra"
 )
 
@@ -40,6 +42,7 @@ func init() {
 	RootCmd.AddCommand(getCmd)
 
 	getCmd.Flags().BoolVarP(&export, "export", "", false, "Export variables")
+	getCmd.Flags().StringVarP(&format, "format", "f", "shell", "Output format: "+strings.Join(formatter.Formats, ", "))
 	getCmd.Flags().BoolVarP(&deserialize, "deserialize", "", true, "Deserialize items")
 }
 
@@ -51,24 +54,23 @@ func getParse(cmd *cobra.Command, args []string) error {
 	tableName := args[0]
 	key := args[1]
 
-	session := newSession(region, profile, endpointURL)
+	session := newSession(region, profile, endpointURL, namespaces)
 
-	return get(session, tableName, key, export, deserialize)
+	return get(session, tableName, key, format, export, deserialize)
 }
 
-func get(session *Session, tableName, key string, export, deserialize bool) error {
-	table := table.NewTable(session.DynamoDB, tableName)
+func get(session *Session, tableName, key, format string, export, deserialize bool) error {
+	table := openTable(session, tableName)
 
 	parsedItem, err := table.Get(key)
 	if err != nil {
 		return err
 	}
 
-	item, err := serializer.DeserializeItem(session.KMS, parsedItem, deserialize)
+	item, err := serializer.DeserializeItem(newSerializerContext(session, tableName), parsedItem, deserialize)
 	if err != nil {
 		return err
 	}
 
-	printItem(item, export)
-	return nil
+	return printItems([]*models.Item{item}, format, export, deserialize)
 }
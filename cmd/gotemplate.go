@@ -0,0 +1,155 @@
+// Copyright © 2017 Jorge Dias <jorge@mrdias.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/diasjorge/dynamokv/models"
+	"github.com/diasjorge/dynamokv/serializer"
+)
+
+// templateData gives templates access to the items of a table. The table is
+// read once and values are deserialized on first use.
+type templateData struct {
+	ctx    *serializer.Context
+	items  map[string]*models.ParsedItem
+	values map[string]string
+}
+
+func newTemplateData(session *Session, tableName string) (*templateData, error) {
+	parsedItems, err := openTable(session, tableName).Read()
+	if err != nil {
+		return nil, err
+	}
+	data := &templateData{
+		ctx:    newSerializerContext(session, tableName),
+		items:  map[string]*models.ParsedItem{},
+		values: map[string]string{},
+	}
+	for _, parsedItem := range parsedItems {
+		data.items[parsedItem.Key] = parsedItem
+	}
+	return data, nil
+}
+
+// value returns the deserialized value of key, or an empty string if it does
+// not exist.
+func (data *templateData) value(key string) (string, error) {
+	if value, ok := data.values[key]; ok {
+		return value, nil
+	}
+	parsedItem, ok := data.items[key]
+	if !ok {
+		return "", nil
+	}
+	item, err := serializer.DeserializeItem(data.ctx, parsedItem, true)
+	if err != nil {
+		return "", fmt.Errorf("Key \"%s\": %s", key, err)
+	}
+	data.values[key] = item.Value
+	return item.Value, nil
+}
+
+// raw returns the stored value of key, or an empty string if it does not
+// exist.
+func (data *templateData) raw(key string) string {
+	if parsedItem, ok := data.items[key]; ok {
+		return parsedItem.Value.Value
+	}
+	return ""
+}
+
+// keys returns the sorted keys starting with prefix.
+func (data *templateData) keys(prefix string) []string {
+	keys := []string{}
+	for key := range data.items {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// templateFuncs returns the functions available to go templates.
+func templateFuncs(data *templateData) texttemplate.FuncMap {
+	return texttemplate.FuncMap{
+		"kv":   data.value,
+		"raw":  data.raw,
+		"keys": data.keys,
+		"default": func(defaultValue, value string) string {
+			if value == "" {
+				return defaultValue
+			}
+			return value
+		},
+		"required": func(message, value string) (string, error) {
+			if value == "" {
+				return "", fmt.Errorf("%s", message)
+			}
+			return value, nil
+		},
+		"b64enc": func(value string) string {
+			return base64.StdEncoding.EncodeToString([]byte(value))
+		},
+		"toJson": func(value interface{}) (string, error) {
+			encoded, err := json.Marshal(value)
+			return string(encoded), err
+		},
+		// quote single quotes the value for shells and env files.
+		"quote": quoteShell,
+		// indent pads every line, including the first, and nindent also
+		// starts a new line, as in "key: {{kv "KEY" | nindent 2}}".
+		"indent": indent,
+		"nindent": func(spaces int, value string) string {
+			return "\n" + indent(spaces, value)
+		},
+	}
+}
+
+func indent(spaces int, value string) string {
+	padding := strings.Repeat(" ", spaces)
+	return padding + strings.Replace(value, "\n", "\n"+padding, -1)
+}
+
+// renderGo renders template with text/template.
+func renderGo(session *Session, tableName, name string, template []byte) ([]byte, error) {
+	data, err := newTemplateData(session, tableName)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := texttemplate.New(name).Funcs(templateFuncs(data)).Parse(string(template))
+	if err != nil {
+		return nil, err
+	}
+	var output bytes.Buffer
+	if err := parsed.Execute(&output, nil); err != nil {
+		return nil, err
+	}
+	return output.Bytes(), nil
+}
@@ -0,0 +1,96 @@
+// Copyright © 2017 Jorge Dias <jorge@mrdias.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/diasjorge/dynamokv/serializer"
+	"github.com/spf13/cobra"
+)
+
+// historyCmd represents the history command
+var historyCmd = &cobra.Command{
+	Use:   "history TABLENAME KEY",
+	Short: "List the versions of a Key",
+	Long: `List the versions of a Key with the time they were written and their author.
+Values are only shown with --show-values.`,
+	RunE: historyParse,
+}
+
+func init() {
+	RootCmd.AddCommand(historyCmd)
+	historyCmd.Flags().BoolVarP(&showValues, "show-values", "", false, "Show deserialized values")
+}
+
+func historyParse(cmd *cobra.Command, args []string) error {
+	if len(args) != 2 {
+		return errors.New("TABLENAME KEY required")
+	}
+
+	tableName, key := args[0], args[1]
+
+	session := newSession(region, profile, endpointURL, namespaces)
+
+	return history(session, tableName, key, showValues)
+}
+
+func history(session *Session, tableName, key string, showValues bool) error {
+	entries, err := openTable(session, tableName).History().List(key)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("No history found for Key \"%s\"", key)
+	}
+
+	serializerContext := newSerializerContext(session, tableName)
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	header := "VERSION\tTIMESTAMP\tAUTHOR\tSERIALIZATION"
+	if showValues {
+		header += "\tVALUE"
+	}
+	fmt.Fprintln(writer, header)
+	for _, entry := range entries {
+		timestamp, author := "-", "-"
+		if !entry.Timestamp.IsZero() {
+			timestamp = entry.Timestamp.Format(time.RFC3339)
+		}
+		if entry.Author != "" {
+			author = entry.Author
+		}
+		line := fmt.Sprintf("%d\t%s\t%s\t%s", entry.Item.Version, timestamp, author, entry.Item.Value.Serialization.Type)
+		if showValues {
+			item, err := serializer.DeserializeItem(serializerContext, entry.Item, true)
+			if err != nil {
+				return err
+			}
+			line += "\t" + strconv.Quote(item.Value)
+		}
+		fmt.Fprintln(writer, line)
+	}
+	return writer.Flush()
+}
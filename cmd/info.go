@@ -0,0 +1,83 @@
+// Copyright © 2017 Jorge Dias <jorge@mrdias.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// infoCmd represents the info command
+var infoCmd = &cobra.Command{
+	Use:   "info TABLENAME KEY",
+	Short: "Show metadata of a Key",
+	Long: `Show the version of a Key and when, by whom and from where it was last written.
+The value is not shown.`,
+	RunE: infoParse,
+}
+
+func init() {
+	RootCmd.AddCommand(infoCmd)
+}
+
+func infoParse(cmd *cobra.Command, args []string) error {
+	if len(args) != 2 {
+		return errors.New("TABLENAME KEY required")
+	}
+
+	tableName, key := args[0], args[1]
+
+	session := newSession(region, profile, endpointURL, namespaces)
+
+	return info(session, tableName, key)
+}
+
+func info(session *Session, tableName, key string) error {
+	item, err := openTable(session, tableName).Get(key)
+	if err != nil {
+		return err
+	}
+
+	updatedAt := "-"
+	if !item.UpdatedAt.IsZero() {
+		updatedAt = item.UpdatedAt.Format(time.RFC3339)
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(writer, "Key:\t%s\n", item.Key)
+	fmt.Fprintf(writer, "Version:\t%d\n", item.Version)
+	fmt.Fprintf(writer, "Serialization:\t%s\n", item.Value.Serialization.Type)
+	fmt.Fprintf(writer, "Updated at:\t%s\n", updatedAt)
+	fmt.Fprintf(writer, "Updated by:\t%s\n", valueOrDash(item.UpdatedBy))
+	fmt.Fprintf(writer, "Source:\t%s\n", valueOrDash(item.Source))
+	return writer.Flush()
+}
+
+func valueOrDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -5,10 +5,16 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/diasjorge/dynamokv/fake"
+	"github.com/diasjorge/dynamokv/models"
+	"github.com/diasjorge/dynamokv/serializer"
+	"github.com/diasjorge/dynamokv/table"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -47,7 +53,7 @@ SERIALIZED_KEY:
 
 	deleteTable()
 
-	store(session, testTableName, configPath)
+	store(session, testTableName, configPath, storeOptions{})
 }
 
 func captureStdout(f func()) []byte {
@@ -65,34 +71,41 @@ func captureStdout(f func()) []byte {
 }
 
 func deleteTable() {
-	session := newSession(testRegion, "", testEndpointURL)
-	_, err := session.DynamoDB.DescribeTable(&dynamodb.DescribeTableInput{TableName: aws.String(testTableName)})
-	if err == nil {
-		session.DynamoDB.DeleteTable(&dynamodb.DeleteTableInput{
-			TableName: aws.String(testTableName),
-		})
-		session.DynamoDB.WaitUntilTableNotExists(&dynamodb.DescribeTableInput{
-			TableName: aws.String(testTableName),
-		})
+	session := newTestSession()
+	table.NewTable(session.DynamoDB, testTableName).DeleteTables()
+}
+
+var fakeDynamoDB = fake.NewDynamoDB()
+var fakeKMS = fake.NewKMS()
+var fakeSTS = fake.NewSTS("arn:aws:iam::000000000000:user/test")
+
+// newTestSession returns a session using DynamoDB Local when DYNAMODB_URL is
+// set and the in-memory fake otherwise. KMS is always faked.
+func newTestSession() *Session {
+	if testEndpointURL != "" {
+		session := newAWSSession(testRegion, "", testEndpointURL, nil)
+		session.KMS = fakeKMS
+		session.STS = fakeSTS
+		return session
 	}
+	return &Session{DynamoDB: fakeDynamoDB, KMS: fakeKMS, STS: fakeSTS}
 }
 
 func TestMain(m *testing.M) {
 	if testEndpointURL == "" {
-		fmt.Println("DYNAMODB_URL not set. Skipping Integration tests.")
-		os.Exit(0)
+		fmt.Println("DYNAMODB_URL not set. Using in-memory DynamoDB.")
 	}
 	exitCode := m.Run()
 	os.Exit(exitCode)
 }
 
 func TestFetch(t *testing.T) {
-	session := newSession(testRegion, "", testEndpointURL)
+	session := newTestSession()
 
 	storeTestConfig(session)
 
 	out := captureStdout(func() {
-		fetch(session, testTableName, false, true)
+		fetch(session, testTableName, "shell", false, true)
 	})
 	expectedOut := "KEY='VALUE'\nSERIALIZED_KEY='VALUE'\n"
 
@@ -100,12 +113,12 @@ func TestFetch(t *testing.T) {
 }
 
 func TestFetchNoDeserialize(t *testing.T) {
-	session := newSession(testRegion, "", testEndpointURL)
+	session := newTestSession()
 
 	storeTestConfig(session)
 
 	out := captureStdout(func() {
-		fetch(session, testTableName, false, false)
+		fetch(session, testTableName, "shell", false, false)
 	})
 	expectedOut := "KEY='VALUE'\nSERIALIZED_KEY='VkFMVUU='\n"
 
@@ -113,12 +126,12 @@ func TestFetchNoDeserialize(t *testing.T) {
 }
 
 func TestFetchExport(t *testing.T) {
-	session := newSession(testRegion, "", testEndpointURL)
+	session := newTestSession()
 
 	storeTestConfig(session)
 
 	out := captureStdout(func() {
-		fetch(session, testTableName, true, true)
+		fetch(session, testTableName, "shell", true, true)
 	})
 	expectedOut := "export KEY='VALUE'\nexport SERIALIZED_KEY='VALUE'\n"
 
@@ -126,13 +139,13 @@ func TestFetchExport(t *testing.T) {
 }
 
 func TestGet(t *testing.T) {
-	session := newSession(testRegion, "", testEndpointURL)
+	session := newTestSession()
 
 	deleteTable()
-	set(session, testTableName, "SINGLE_KEY", "SINGLE_VALUE", "base64", map[string]string{})
+	set(session, testTableName, "SINGLE_KEY", "SINGLE_VALUE", setOptions{serializationType: "base64", serializationOptions: map[string]string{}})
 
 	out := captureStdout(func() {
-		get(session, testTableName, "SINGLE_KEY", false, true)
+		get(session, testTableName, "SINGLE_KEY", "shell", false, true)
 	})
 	expectedOut := "SINGLE_KEY='SINGLE_VALUE'\n"
 
@@ -140,13 +153,13 @@ func TestGet(t *testing.T) {
 }
 
 func TestGetNoDeserialize(t *testing.T) {
-	session := newSession(testRegion, "", testEndpointURL)
+	session := newTestSession()
 
 	deleteTable()
-	set(session, testTableName, "SINGLE_KEY", "SINGLE_VALUE", "base64", map[string]string{})
+	set(session, testTableName, "SINGLE_KEY", "SINGLE_VALUE", setOptions{serializationType: "base64", serializationOptions: map[string]string{}})
 
 	out := captureStdout(func() {
-		get(session, testTableName, "SINGLE_KEY", false, false)
+		get(session, testTableName, "SINGLE_KEY", "shell", false, false)
 	})
 	expectedOut := "SINGLE_KEY='U0lOR0xFX1ZBTFVF'\n"
 
@@ -154,15 +167,905 @@ func TestGetNoDeserialize(t *testing.T) {
 }
 
 func TestGetExport(t *testing.T) {
-	session := newSession(testRegion, "", testEndpointURL)
+	session := newTestSession()
 
 	deleteTable()
-	set(session, testTableName, "SINGLE_KEY", "SINGLE_VALUE", "base64", map[string]string{})
+	set(session, testTableName, "SINGLE_KEY", "SINGLE_VALUE", setOptions{serializationType: "base64", serializationOptions: map[string]string{}})
 
 	out := captureStdout(func() {
-		get(session, testTableName, "SINGLE_KEY", true, true)
+		get(session, testTableName, "SINGLE_KEY", "shell", true, true)
 	})
 	expectedOut := "export SINGLE_KEY='SINGLE_VALUE'\n"
 
 	assert.Equal(t, expectedOut, string(out))
 }
+
+func TestStoreMoreThanBatchLimit(t *testing.T) {
+	session := newTestSession()
+
+	config := ""
+	for i := 0; i < 60; i++ {
+		config += fmt.Sprintf("KEY_%02d: VALUE_%02d\n", i, i)
+	}
+	configPath := writeConfig(config)
+
+	deleteTable()
+
+	err := store(session, testTableName, configPath, storeOptions{})
+	assert.NoError(t, err)
+
+	out := captureStdout(func() {
+		fetch(session, testTableName, "shell", false, true)
+	})
+
+	assert.Equal(t, 60, strings.Count(string(out), "\n"))
+}
+
+func TestExec(t *testing.T) {
+	session := newTestSession()
+
+	storeTestConfig(session)
+
+	out := captureStdout(func() {
+		execCommand(session, testTableName, []string{"sh", "-c", "echo $KEY $SERIALIZED_KEY"}, false, true)
+	})
+
+	assert.Equal(t, "VALUE VALUE\n", string(out))
+}
+
+func TestExecExitCode(t *testing.T) {
+	session := newTestSession()
+
+	storeTestConfig(session)
+
+	err := execCommand(session, testTableName, []string{"sh", "-c", "exit 3"}, false, false)
+
+	assert.Equal(t, exitError{code: 3}, err)
+}
+
+// executeCommand runs dynamokv with args as given on the command line.
+func executeCommand(args ...string) error {
+	newSession = func(region, profile, endpointURL string, namespaces []string) *Session {
+		session := newTestSession()
+		session.Namespaces = namespaces
+		return session
+	}
+	defer func() { newSession = newAWSSession }()
+	RootCmd.SetArgs(args)
+	return RootCmd.Execute()
+}
+
+func TestExecCommandLine(t *testing.T) {
+	storeTestConfig(newTestSession())
+
+	out := captureStdout(func() {
+		err := executeCommand("exec", testTableName, "--", "sh", "-c", "echo $KEY")
+		assert.NoError(t, err)
+	})
+
+	assert.Equal(t, "VALUE\n", string(out))
+}
+
+func TestDelete(t *testing.T) {
+	session := newTestSession()
+
+	storeTestConfig(session)
+
+	err := deleteKeys(session, testTableName, []string{"SERIALIZED_*"}, "", true)
+	assert.NoError(t, err)
+
+	out := captureStdout(func() {
+		fetch(session, testTableName, "shell", false, true)
+	})
+
+	assert.Equal(t, "KEY='VALUE'\n", string(out))
+}
+
+func TestDeleteMissingKey(t *testing.T) {
+	session := newTestSession()
+
+	storeTestConfig(session)
+
+	err := deleteKeys(session, testTableName, []string{"KEY", "MISSING_KEY"}, "", true)
+	assert.EqualError(t, err, "Keys not found: MISSING_KEY")
+}
+
+func TestSelectKeys(t *testing.T) {
+	existing := []string{"app/db", "app/web", "apple", "DB_HOST", "DB_PORT", "OTHER"}
+
+	keys, missing, err := selectKeys(existing, []string{"app*", "DB_[HX]OS?", "NONE*"}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"DB_HOST", "app/db", "app/web", "apple"}, keys)
+	assert.Equal(t, []string{"NONE*"}, missing)
+
+	_, _, err = selectKeys(existing, []string{"DB_[HOST"}, "")
+	assert.EqualError(t, err, "Invalid pattern DB_[HOST")
+}
+
+func TestDeleteUnmatchedSelectors(t *testing.T) {
+	session := newTestSession()
+
+	storeTestConfig(session)
+
+	err := deleteKeys(session, testTableName, []string{"MISSING_*", "SERIALIZED_*"}, "OTHER_", true)
+	assert.EqualError(t, err, "Keys not found: MISSING_*, --prefix OTHER_")
+
+	out := captureStdout(func() {
+		fetch(session, testTableName, "shell", false, true)
+	})
+	assert.Equal(t, "KEY='VALUE'\n", string(out))
+}
+
+func TestSetPersistsSerializationOptions(t *testing.T) {
+	session := newTestSession()
+
+	deleteTable()
+	set(session, testTableName, "SINGLE_KEY", "SINGLE_VALUE", setOptions{serializationType: "base64", serializationOptions: map[string]string{"note": "test"}})
+
+	parsedItem, err := table.NewTable(session.DynamoDB, testTableName).Get("SINGLE_KEY")
+	assert.NoError(t, err)
+
+	assert.Equal(t, "base64", parsedItem.Value.Serialization.Type)
+	assert.Equal(t, map[string]string{"note": "test"}, parsedItem.Value.Serialization.Options)
+}
+
+func TestGetChainedSerialization(t *testing.T) {
+	session := newTestSession()
+
+	deleteTable()
+	set(session, testTableName, "SINGLE_KEY", "SINGLE_VALUE", setOptions{serializationType: "gzip,base64", serializationOptions: map[string]string{}})
+
+	out := captureStdout(func() {
+		get(session, testTableName, "SINGLE_KEY", "shell", false, true)
+	})
+	expectedOut := "SINGLE_KEY='SINGLE_VALUE'\n"
+
+	assert.Equal(t, expectedOut, string(out))
+}
+
+func TestGetKMS(t *testing.T) {
+	session := newTestSession()
+
+	deleteTable()
+	err := set(session, testTableName, "SECRET", "SECRET_VALUE", setOptions{serializationType: "kms", serializationOptions: map[string]string{"key": "alias/test"}})
+	assert.NoError(t, err)
+
+	out := captureStdout(func() {
+		get(session, testTableName, "SECRET", "shell", false, true)
+	})
+
+	assert.Equal(t, "SECRET='SECRET_VALUE'\n", string(out))
+}
+
+func TestFetchEnvelope(t *testing.T) {
+	session := newTestSession()
+
+	config := `
+FIRST:
+  serialization:
+    type: envelope
+    options:
+      key: alias/test
+  value: FIRST_VALUE
+SECOND:
+  serialization:
+    - gzip
+    - type: envelope
+      options:
+        key: alias/test
+  value: SECOND_VALUE
+`
+	deleteTable()
+	err := store(session, testTableName, writeConfig(config), storeOptions{})
+	assert.NoError(t, err)
+
+	out := captureStdout(func() {
+		fetch(session, testTableName, "shell", false, true)
+	})
+
+	assert.Equal(t, "FIRST='FIRST_VALUE'\nSECOND='SECOND_VALUE'\n", string(out))
+}
+
+func TestRotate(t *testing.T) {
+	session := newTestSession()
+
+	deleteTable()
+	set(session, testTableName, "KMS_SECRET", "KMS_VALUE", setOptions{serializationType: "kms", serializationOptions: map[string]string{"key": "alias/old"}})
+	set(session, testTableName, "ENVELOPE_SECRET", "ENVELOPE_VALUE", setOptions{serializationType: "envelope", serializationOptions: map[string]string{"key": "alias/old"}})
+	set(session, testTableName, "OTHER_SECRET", "OTHER_VALUE", setOptions{serializationType: "kms", serializationOptions: map[string]string{"key": "alias/other"}})
+
+	err := rotate(session, testTableName, "alias/old", "alias/new", false)
+	assert.NoError(t, err)
+
+	table := table.NewTable(session.DynamoDB, testTableName)
+	for key, expected := range map[string]string{"KMS_SECRET": "alias/new", "ENVELOPE_SECRET": "alias/new", "OTHER_SECRET": "alias/other"} {
+		parsedItem, err := table.Get(key)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, parsedItem.Value.Serialization.Options["key"])
+	}
+
+	out := captureStdout(func() {
+		fetch(session, testTableName, "shell", false, true)
+	})
+
+	assert.Equal(t, "ENVELOPE_SECRET='ENVELOPE_VALUE'\nKMS_SECRET='KMS_VALUE'\nOTHER_SECRET='OTHER_VALUE'\n", string(out))
+}
+
+func TestRotateMatchesResolvedKey(t *testing.T) {
+	session := newTestSession()
+	arn := "arn:aws:kms:eu-west-1:000000000000:key/current"
+	fakeKMS.Aliases["alias/current"] = arn
+	defer delete(fakeKMS.Aliases, "alias/current")
+
+	deleteTable()
+	set(session, testTableName, "ALIAS_SECRET", "ALIAS_VALUE", setOptions{serializationType: "kms", serializationOptions: map[string]string{"key": "alias/current"}})
+	// Envelope items need their key option, without it the key is unknown
+	err := table.NewTable(session.DynamoDB, testTableName).Write([]*models.Item{
+		{Key: "UNKNOWN_SECRET", Value: "VALUE", Serialization: "envelope"},
+	})
+	assert.NoError(t, err)
+
+	out := captureStdout(func() {
+		err := rotate(session, testTableName, arn, "alias/new", false)
+		assert.EqualError(t, err, "Could not determine the KMS key of 1 encrypted item(s): UNKNOWN_SECRET")
+	})
+	assert.Equal(t, "Rotated ALIAS_SECRET\n", string(out))
+
+	parsedItem, err := table.NewTable(session.DynamoDB, testTableName).Get("ALIAS_SECRET")
+	assert.NoError(t, err)
+	assert.Equal(t, "alias/new", parsedItem.Value.Serialization.Options["key"])
+}
+
+func TestRotateDryRunAndResume(t *testing.T) {
+	session := newTestSession()
+
+	deleteTable()
+	set(session, testTableName, "CHAINED_SECRET", "CHAINED_VALUE", setOptions{serializationType: "gzip,kms", serializationOptions: map[string]string{"key": "alias/old"}})
+
+	out := captureStdout(func() {
+		err := rotate(session, testTableName, "alias/old", "alias/new", true)
+		assert.NoError(t, err)
+	})
+	assert.Equal(t, "Would rotate CHAINED_SECRET\n", string(out))
+
+	table := table.NewTable(session.DynamoDB, testTableName)
+	parsedItem, err := table.Get("CHAINED_SECRET")
+	assert.NoError(t, err)
+	assert.Equal(t, "alias/old", parsedItem.Value.Serialization.Options["key"])
+
+	out = captureStdout(func() {
+		err := rotate(session, testTableName, "alias/old", "alias/new", false)
+		assert.NoError(t, err)
+	})
+	assert.Equal(t, "Rotated CHAINED_SECRET\n", string(out))
+
+	// Running the rotation again has nothing left to do
+	out = captureStdout(func() {
+		err := rotate(session, testTableName, "alias/old", "alias/new", false)
+		assert.NoError(t, err)
+	})
+	assert.Equal(t, "", string(out))
+
+	parsedItem, err = table.Get("CHAINED_SECRET")
+	assert.NoError(t, err)
+	assert.Equal(t, "gzip,kms", parsedItem.Value.Serialization.Type)
+	assert.Equal(t, "alias/new", parsedItem.Value.Serialization.Options["key"])
+
+	out = captureStdout(func() {
+		get(session, testTableName, "CHAINED_SECRET", "", false, true)
+	})
+	assert.Equal(t, "CHAINED_SECRET='CHAINED_VALUE'\n", string(out))
+}
+
+func TestFetchYAMLCanBeStored(t *testing.T) {
+	session := newTestSession()
+
+	storeTestConfig(session)
+
+	out := captureStdout(func() {
+		fetch(session, testTableName, "yaml", false, true)
+	})
+
+	deleteTable()
+	err := store(session, testTableName, writeConfig(string(out)), storeOptions{})
+	assert.NoError(t, err)
+
+	out = captureStdout(func() {
+		fetch(session, testTableName, "shell", false, false)
+	})
+
+	assert.Equal(t, "KEY='VALUE'\nSERIALIZED_KEY='VkFMVUU='\n", string(out))
+}
+
+func TestDumpAndStore(t *testing.T) {
+	session := newTestSession()
+
+	config := `
+KEY: VALUE
+SERIALIZED_KEY:
+  serialization: base64
+  value: VALUE
+SECRET:
+  serialization:
+    - gzip
+    - type: kms
+      options:
+        key: alias/test
+  value: SECRET_VALUE
+`
+	deleteTable()
+	err := store(session, testTableName, writeConfig(config), storeOptions{})
+	assert.NoError(t, err)
+
+	before, err := table.NewTable(session.DynamoDB, testTableName).Read()
+	assert.NoError(t, err)
+
+	for _, plaintext := range []bool{false, true} {
+		dumpFile := writeConfig("")
+		err = dump(session, testTableName, dumpFile, plaintext)
+		assert.NoError(t, err)
+
+		deleteTable()
+		err = store(session, testTableName, dumpFile, storeOptions{})
+		assert.NoError(t, err)
+
+		after, err := table.NewTable(session.DynamoDB, testTableName).Read()
+		assert.NoError(t, err)
+		if plaintext {
+			out := captureStdout(func() {
+				fetch(session, testTableName, "shell", false, true)
+			})
+			assert.Equal(t, "KEY='VALUE'\nSECRET='SECRET_VALUE'\nSERIALIZED_KEY='VALUE'\n", string(out))
+		} else {
+			// The metadata records the restore, not the original write.
+			for i := range before {
+				before[i].Metadata, after[i].Metadata = models.Metadata{}, models.Metadata{}
+			}
+			assert.Equal(t, before, after)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	session := newTestSession()
+
+	config := `
+KEY: VALUE
+CHANGED_KEY: OLD_VALUE
+REMOVED_KEY: VALUE
+SECRET:
+  serialization:
+    type: kms
+    options:
+      key: alias/test
+  value: OLD_SECRET
+`
+	deleteTable()
+	err := store(session, testTableName, writeConfig(config), storeOptions{})
+	assert.NoError(t, err)
+
+	config = `
+KEY: VALUE
+CHANGED_KEY: NEW_VALUE
+ADDED_KEY: VALUE
+SECRET:
+  serialization:
+    type: kms
+    options:
+      key: alias/test
+  value: NEW_SECRET
+`
+	configPath := writeConfig(config)
+	changes, err := diff(session, testTableName, configPath, "")
+	assert.NoError(t, err)
+
+	out := captureStdout(func() {
+		printChanges(changes, false, false, false)
+	})
+	assert.Equal(t, "+ ADDED_KEY\n~ CHANGED_KEY: ***** => *****\n? REMOVED_KEY (kept without --prune)\n~ SECRET: ***** => *****\n", string(out))
+
+	out = captureStdout(func() {
+		printChanges(changes, true, false, false)
+	})
+	assert.Equal(t, "+ ADDED_KEY\n~ CHANGED_KEY: ***** => *****\n- REMOVED_KEY\n~ SECRET: ***** => *****\n", string(out))
+
+	out = captureStdout(func() {
+		printChanges(changes, true, true, false)
+	})
+	assert.Equal(t, "+ ADDED_KEY\n~ CHANGED_KEY: \"OLD_VALUE\" => \"NEW_VALUE\"\n- REMOVED_KEY\n~ SECRET: ***** => *****\n", string(out))
+
+	out = captureStdout(func() {
+		printChanges(changes, true, true, true)
+	})
+	assert.Contains(t, string(out), "~ SECRET: \"OLD_SECRET\" => \"NEW_SECRET\"\n")
+}
+
+func TestDiffExitCodeCountsRemovals(t *testing.T) {
+	session := newTestSession()
+
+	deleteTable()
+	assert.NoError(t, store(session, testTableName, writeConfig("KEY: VALUE\nREMOVED_KEY: VALUE\n"), storeOptions{}))
+	defer func() { exitCodeOnDiff = false }()
+
+	out := captureStdout(func() {
+		err := executeCommand("diff", testTableName, writeConfig("KEY: VALUE\n"), "--exit-code")
+		assert.Equal(t, exitError{code: 1}, err)
+	})
+	assert.Equal(t, "? REMOVED_KEY (kept without --prune)\n", string(out))
+}
+
+func TestDiffNoChanges(t *testing.T) {
+	session := newTestSession()
+
+	storeTestConfig(session)
+
+	dumpFile := writeConfig("")
+	assert.NoError(t, dump(session, testTableName, dumpFile, false))
+
+	changes, err := diff(session, testTableName, dumpFile, "")
+	assert.NoError(t, err)
+	assert.Empty(t, changes)
+}
+
+func TestStorePrune(t *testing.T) {
+	session := newTestSession()
+
+	storeTestConfig(session)
+
+	configPath := writeConfig("KEY: NEW_VALUE\n")
+
+	out := captureStdout(func() {
+		err := store(session, testTableName, configPath, storeOptions{prune: true, dryRun: true, maxDelete: 10, maxDeletePercent: 50})
+		assert.NoError(t, err)
+	})
+	assert.Equal(t, "Would write KEY\nWould delete SERIALIZED_KEY\n", string(out))
+
+	out = captureStdout(func() {
+		fetch(session, testTableName, "shell", false, true)
+	})
+	assert.Equal(t, "KEY='VALUE'\nSERIALIZED_KEY='VALUE'\n", string(out))
+
+	err := store(session, testTableName, configPath, storeOptions{prune: true, maxDelete: 10, maxDeletePercent: 25})
+	assert.EqualError(t, err, "Refusing to prune 1 keys (50% of the table): limits are 10 keys and 25%. Use --force to prune anyway")
+
+	out = captureStdout(func() {
+		fetch(session, testTableName, "shell", false, true)
+	})
+	assert.Equal(t, "KEY='VALUE'\nSERIALIZED_KEY='VALUE'\n", string(out))
+
+	captureStdout(func() {
+		err = store(session, testTableName, configPath, storeOptions{prune: true, force: true})
+	})
+	assert.NoError(t, err)
+
+	out = captureStdout(func() {
+		fetch(session, testTableName, "shell", false, true)
+	})
+	assert.Equal(t, "KEY='NEW_VALUE'\n", string(out))
+}
+
+func TestStorePruneLimitsUseTableBeforeWrite(t *testing.T) {
+	session := newTestSession()
+
+	oldConfig, newConfig := "", ""
+	for i := 0; i < 10; i++ {
+		oldConfig += fmt.Sprintf("OLD_%d: VALUE\n", i)
+		newConfig += fmt.Sprintf("NEW_%d: VALUE\n", i)
+	}
+	deleteTable()
+	assert.NoError(t, store(session, testTableName, writeConfig(oldConfig), storeOptions{}))
+
+	configPath := writeConfig(newConfig)
+	options := storeOptions{prune: true, dryRun: true, maxDelete: 10, maxDeletePercent: 50}
+	expected := "Refusing to prune 10 keys (100% of the table): limits are 10 keys and 50%. Use --force to prune anyway"
+
+	err := store(session, testTableName, configPath, options)
+	assert.EqualError(t, err, expected)
+
+	options.dryRun = false
+	err = store(session, testTableName, configPath, options)
+	assert.EqualError(t, err, expected)
+
+	parsedItems, err := table.NewTable(session.DynamoDB, testTableName).Read()
+	assert.NoError(t, err)
+	assert.Len(t, parsedItems, 10)
+	for _, parsedItem := range parsedItems {
+		assert.True(t, strings.HasPrefix(parsedItem.Key, "OLD_"))
+	}
+}
+
+func TestHistoryAndRollback(t *testing.T) {
+	session := newTestSession()
+
+	deleteTable()
+	set(session, testTableName, "SECRET", "FIRST", setOptions{serializationType: "kms", serializationOptions: map[string]string{"key": "alias/test"}})
+	set(session, testTableName, "SECRET", "SECOND", setOptions{serializationType: "kms", serializationOptions: map[string]string{"key": "alias/test"}})
+
+	entries, err := table.NewTable(session.DynamoDB, testTableName).History().List("SECRET")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, int64(1), entries[0].Item.Version)
+	assert.Equal(t, "arn:aws:iam::000000000000:user/test", entries[0].Author)
+	assert.NotContains(t, entries[0].Item.Value.Value, "FIRST")
+
+	out := captureStdout(func() {
+		err = history(session, testTableName, "SECRET", true)
+	})
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), "\"FIRST\"")
+	assert.Contains(t, string(out), "\"SECOND\"")
+
+	captureStdout(func() {
+		err = rollback(session, testTableName, "SECRET", 1)
+	})
+	assert.NoError(t, err)
+
+	out = captureStdout(func() {
+		get(session, testTableName, "SECRET", "shell", false, true)
+	})
+	assert.Equal(t, "SECRET='FIRST'\n", string(out))
+
+	parsedItem, err := table.NewTable(session.DynamoDB, testTableName).Get("SECRET")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), parsedItem.Version)
+}
+
+func TestSetIfVersion(t *testing.T) {
+	session := newTestSession()
+
+	deleteTable()
+	err := set(session, testTableName, "KEY", "FIRST", setOptions{ifAbsent: true})
+	assert.NoError(t, err)
+
+	err = set(session, testTableName, "KEY", "SECOND", setOptions{ifAbsent: true})
+	assert.Equal(t, &table.ConflictError{Keys: []string{"KEY"}}, err)
+
+	err = set(session, testTableName, "KEY", "SECOND", setOptions{ifVersion: 1})
+	assert.NoError(t, err)
+
+	err = set(session, testTableName, "KEY", "THIRD", setOptions{ifVersion: 1})
+	assert.IsType(t, &table.ConflictError{}, err)
+
+	out := captureStdout(func() {
+		get(session, testTableName, "KEY", "raw", false, true)
+	})
+	assert.Equal(t, "SECOND\n", string(out))
+}
+
+func TestStoreSnapshot(t *testing.T) {
+	session := newTestSession()
+
+	storeTestConfig(session)
+
+	snapshot := captureStdout(func() {
+		fetch(session, testTableName, "json", false, false)
+	})
+	snapshotPath := writeConfig(string(snapshot))
+	configPath := writeConfig("KEY: NEW_VALUE\nADDED_KEY: ADDED\n")
+
+	err := store(session, testTableName, configPath, storeOptions{snapshot: snapshotPath})
+	assert.NoError(t, err)
+
+	err = store(session, testTableName, configPath, storeOptions{snapshot: snapshotPath})
+	assert.Equal(t, &table.ConflictError{Keys: []string{"ADDED_KEY", "KEY"}}, err)
+}
+
+func TestInfo(t *testing.T) {
+	session := newTestSession()
+
+	storeTestConfig(session)
+	set(session, testTableName, "KEY", "NEW_VALUE", setOptions{})
+
+	out := captureStdout(func() {
+		err := info(session, testTableName, "KEY")
+		assert.NoError(t, err)
+	})
+	assert.Contains(t, string(out), "Version:        2\n")
+	assert.Contains(t, string(out), "Updated by:     arn:aws:iam::000000000000:user/test\n")
+	assert.Contains(t, string(out), "Source:         set\n")
+	assert.NotContains(t, string(out), "Updated at:     -")
+
+	out = captureStdout(func() {
+		fetch(session, testTableName, "json", false, true)
+	})
+	assert.Contains(t, string(out), `"source": "store `)
+	assert.Contains(t, string(out), `"updated_by": "arn:aws:iam::000000000000:user/test"`)
+}
+
+func TestFetchNamespaces(t *testing.T) {
+	session := newTestSession()
+
+	deleteTable()
+	common := session.withNamespace("common")
+	prod := session.withNamespace("prod")
+	assert.NoError(t, store(common, testTableName, writeConfig("HOST: localhost\nPORT: '80'\n"), storeOptions{}))
+	assert.NoError(t, store(prod, testTableName, writeConfig("HOST: example.com\n"), storeOptions{}))
+	assert.NoError(t, set(prod, testTableName, "SECRET", "PROD_SECRET", setOptions{serializationType: "kms", serializationOptions: map[string]string{"key": "alias/test"}}))
+
+	layered := *session
+	layered.Namespaces = []string{"common", "prod"}
+	out := captureStdout(func() {
+		err := fetch(&layered, testTableName, "shell", false, true)
+		assert.NoError(t, err)
+	})
+	assert.Equal(t, "HOST='example.com'\nPORT='80'\nSECRET='PROD_SECRET'\n", string(out))
+
+	out = captureStdout(func() {
+		fetch(session, testTableName, "shell", false, true)
+	})
+	assert.Equal(t, "", string(out))
+
+	// Ciphertexts are bound to their namespace.
+	parsedItem, err := openTable(prod, testTableName).Get("SECRET")
+	assert.NoError(t, err)
+	_, err = serializer.DeserializeItem(newSerializerContext(common, testTableName), parsedItem, true)
+	assert.Error(t, err)
+}
+
+func TestKeysWithNamespaceSeparator(t *testing.T) {
+	storeTestConfig(newTestSession())
+
+	expected := `Invalid key "a::b": keys can't contain "::"`
+	assert.EqualError(t, executeCommand("set", testTableName, "a::b", "VALUE"), expected)
+	assert.EqualError(t, executeCommand("rollback", testTableName, "a::b", "--version", "1"), expected)
+	assert.EqualError(t, executeCommand("store", testTableName, writeConfig("a::b: VALUE\n")), expected)
+
+	parsedItems, err := table.NewTable(newTestSession().DynamoDB, testTableName).Read()
+	assert.NoError(t, err)
+	assert.Len(t, parsedItems, 2)
+}
+
+func TestTableCreate(t *testing.T) {
+	session := newTestSession()
+
+	deleteTable()
+	err := set(session, testTableName, "KEY", "VALUE", setOptions{noCreate: true})
+	assert.EqualError(t, err, "Table "+testTableName+" does not exist. Create it with dynamokv table create")
+
+	out := captureStdout(func() {
+		err = tableCreate(session, testTableName, &table.CreateOptions{
+			BillingMode: dynamodb.BillingModePayPerRequest,
+			Tags:        map[string]string{"team": "platform"},
+		})
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "Created table "+testTableName+"\n", string(out))
+
+	err = tableCreate(session, testTableName, table.DefaultCreateOptions())
+	assert.EqualError(t, err, "Table "+testTableName+" already exists")
+
+	err = set(session, testTableName, "KEY", "VALUE", setOptions{noCreate: true})
+	assert.NoError(t, err)
+}
+
+func TestNoCreateRequiresHistoryTable(t *testing.T) {
+	session := newTestSession()
+
+	storeTestConfig(session)
+	_, err := session.DynamoDB.DeleteTable(&dynamodb.DeleteTableInput{TableName: aws.String(testTableName + table.HistorySuffix)})
+	assert.NoError(t, err)
+
+	expected := "Table " + testTableName + " has no history table. Run dynamokv table migrate " + testTableName
+	err = set(session, testTableName, "KEY", "VALUE", setOptions{noCreate: true})
+	assert.EqualError(t, err, expected)
+	err = store(session, testTableName, writeConfig("KEY: VALUE\n"), storeOptions{noCreate: true})
+	assert.EqualError(t, err, expected)
+}
+
+func TestRotateAndRollbackDoNotCreateTables(t *testing.T) {
+	session := newTestSession()
+
+	deleteTable()
+	expected := "Table " + testTableName + " does not exist. Create it with dynamokv table create"
+	err := rotate(session, testTableName, "alias/old", "alias/new", false)
+	assert.EqualError(t, err, expected)
+	err = rollback(session, testTableName, "KEY", 1)
+	assert.EqualError(t, err, expected)
+
+	exists, err := table.NewTable(session.DynamoDB, testTableName).Exists()
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestTableCommands(t *testing.T) {
+	session := newTestSession()
+
+	deleteTable()
+	captureStdout(func() {
+		tableCreate(session, testTableName, &table.CreateOptions{
+			BillingMode:   dynamodb.BillingModeProvisioned,
+			ReadCapacity:  2,
+			WriteCapacity: 1,
+			Tags:          map[string]string{"team": "platform"},
+		})
+	})
+
+	out := captureStdout(func() {
+		err := tableList(session)
+		assert.NoError(t, err)
+	})
+	assert.Contains(t, strings.Split(string(out), "\n"), testTableName)
+
+	out = captureStdout(func() {
+		err := tableDescribe(session, testTableName)
+		assert.NoError(t, err)
+	})
+	assert.Contains(t, string(out), "Schema version:          1\n")
+	assert.Contains(t, string(out), "Billing mode:            PROVISIONED (2 RCU, 1 WCU)\n")
+	assert.Contains(t, string(out), "Tags:                    dynamokv:schema-version=1, team=platform\n")
+
+	out = captureStdout(func() {
+		err := tableMigrate(session, testTableName)
+		assert.NoError(t, err)
+	})
+	assert.Equal(t, "Table "+testTableName+" is up to date\n", string(out))
+
+	out = captureStdout(func() {
+		err := tableDelete(session, testTableName, true)
+		assert.NoError(t, err)
+	})
+	assert.Equal(t, "Deleted table "+testTableName+"\n", string(out))
+	assert.Error(t, tableDescribe(session, testTableName))
+}
+
+func TestTemplate(t *testing.T) {
+	session := newTestSession()
+
+	storeTestConfig(session)
+
+	templateFile := writeConfig("key={{KEY}} raw={{RAW:SERIALIZED_KEY}}")
+	out := captureStdout(func() {
+		err := template(session, testTableName, templateFile, "", templateOptions{})
+		assert.NoError(t, err)
+	})
+	assert.Equal(t, "key=VALUE raw=VkFMVUU=\n", string(out))
+}
+
+func TestGoTemplate(t *testing.T) {
+	session := newTestSession()
+
+	storeTestConfig(session)
+	set(session, testTableName, "DB_PASSWORD", "p'ss", setOptions{serializationType: "kms", serializationOptions: map[string]string{"key": "alias/test"}})
+
+	templateFile := writeConfig(`{{range keys "DB_"}}{{.}}={{kv . | quote}}
+{{end}}{{kv "MISSING" | default "fallback"}} {{raw "SERIALIZED_KEY"}} {{kv "KEY" | b64enc}} {{toJson (keys "")}}
+config:
+{{kv "KEY" | printf "a: %s\nb: %s" "1" | indent 2}}
+nested:{{kv "KEY" | printf "a: %s\nb: %s" "1" | nindent 2}}
+{{if kv "MISSING"}}unreachable{{end}}`)
+	out := captureStdout(func() {
+		err := template(session, testTableName, templateFile, "", templateOptions{engine: engineGo})
+		assert.NoError(t, err)
+	})
+	assert.Equal(t, `DB_PASSWORD='p'\''ss'
+fallback VkFMVUU= VkFMVUU= ["DB_PASSWORD","KEY","SERIALIZED_KEY"]
+config:
+  a: 1
+  b: VALUE
+nested:
+  a: 1
+  b: VALUE
+
+`, string(out))
+
+	templateFile = writeConfig(`{{kv "MISSING" | required "MISSING is required"}}`)
+	err := template(session, testTableName, templateFile, "", templateOptions{engine: engineGo})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "MISSING is required")
+}
+
+func TestTemplateReportsAllErrors(t *testing.T) {
+	session := newTestSession()
+
+	storeTestConfig(session)
+	set(session, testTableName, "SECRET", "SECRET_VALUE", setOptions{serializationType: "kms", serializationOptions: map[string]string{"key": "alias/test"}})
+	set(session, testTableName, "BROKEN", "not encrypted", setOptions{serializationType: "kms", serializationOptions: map[string]string{"key": "alias/test"}})
+	table := openTable(session, testTableName)
+	parsedItem, _ := table.Get("BROKEN")
+	table.Write([]*models.Item{{Key: "BROKEN", Value: "invalid", Serialization: parsedItem.Value.Serialization.Type, SerializationOptions: parsedItem.Value.Serialization.Options}})
+
+	templateFile := writeConfig("{{KEY}} {{MISSING}}\n{{SECRET}} {{KEY}}\n{{BROKEN}}\n{{MISSING}} {{OTHER_MISSING}}")
+	err := template(session, testTableName, templateFile, "", templateOptions{})
+	assert.IsType(t, &templateError{}, err)
+	errors := err.(*templateError).errors
+	assert.Len(t, errors, 4)
+	assert.Equal(t, `line 1: Key "MISSING" not found`, errors[0])
+	assert.Contains(t, errors[1], `line 3: Key "BROKEN": `)
+	assert.Equal(t, `line 4: Key "MISSING" not found`, errors[2])
+	assert.Equal(t, `line 4: Key "OTHER_MISSING" not found`, errors[3])
+}
+
+func TestTemplateDefaults(t *testing.T) {
+	session := newTestSession()
+
+	storeTestConfig(session)
+
+	templateFile := writeConfig("{{KEY|unused}} {{MISSING|default text}} [{{OPT:MISSING}}] [{{MISSING|}}] {{OPT:KEY}}")
+	out := captureStdout(func() {
+		err := template(session, testTableName, templateFile, "", templateOptions{})
+		assert.NoError(t, err)
+	})
+	assert.Equal(t, "VALUE default text [] [] VALUE\n", string(out))
+
+	err := template(session, testTableName, templateFile, "", templateOptions{strict: true})
+	assert.IsType(t, &templateError{}, err)
+	assert.Len(t, err.(*templateError).errors, 3)
+}
+
+func TestTemplateEscaping(t *testing.T) {
+	session := newTestSession()
+
+	storeTestConfig(session)
+	set(session, testTableName, "PASSWORD", `it's "<p&ss>"`, setOptions{})
+
+	templateFile := writeConfig(`json: {{JSON:PASSWORD}}
+yaml: {{YAML:PASSWORD}}
+shell: {{SHELL:PASSWORD}}
+url: {{URL:PASSWORD}}
+xml: {{XML:PASSWORD}}
+b64: {{B64:KEY}} {{RAW,B64:SERIALIZED_KEY}} {{B64,JSON:KEY}}
+opt: {{OPT,JSON:MISSING}} {{JSON:MISSING|a"b}}`)
+	out := captureStdout(func() {
+		err := template(session, testTableName, templateFile, "", templateOptions{})
+		assert.NoError(t, err)
+	})
+	assert.Equal(t, `json: "it's \"<p&ss>\""
+yaml: "it's \"<p&ss>\""
+shell: 'it'\''s "<p&ss>"'
+url: it%27s+%22%3Cp%26ss%3E%22
+xml: it&#39;s &#34;&lt;p&amp;ss&gt;&#34;
+b64: VkFMVUU= VmtGTVZVVT0= "VkFMVUU="
+opt: "" "a\"b"
+`, string(out))
+
+	templateFile = writeConfig("{{FOO,JSON:KEY}}")
+	err := template(session, testTableName, templateFile, "", templateOptions{})
+	assert.EqualError(t, err, "Processing template error:\n  line 1: Unknown modifier FOO for Key \"KEY\"")
+}
+
+func TestTemplateWatch(t *testing.T) {
+	session := newTestSession()
+
+	storeTestConfig(session)
+
+	dir, err := ioutil.TempDir("", "watch")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	outputFile := filepath.Join(dir, "output")
+	reloadFile := filepath.Join(dir, "reloads")
+
+	templateFile := writeConfig("{{KEY}}")
+	options := templateOptions{
+		interval: 5 * time.Millisecond,
+		debounce: 20 * time.Millisecond,
+		exec:     fmt.Sprintf("echo reload >> %s", reloadFile),
+	}
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- watch(session, testTableName, templateFile, outputFile, options, stop)
+	}()
+
+	fileContains := func(filename, expected string) func() bool {
+		return func() bool {
+			content, _ := ioutil.ReadFile(filename)
+			return string(content) == expected
+		}
+	}
+	assert.Eventually(t, fileContains(outputFile, "VALUE"), time.Second, time.Millisecond)
+	assert.Eventually(t, fileContains(reloadFile, "reload\n"), time.Second, time.Millisecond)
+
+	set(session, testTableName, "KEY", "FIRST", setOptions{})
+	set(session, testTableName, "KEY", "SECOND", setOptions{})
+	assert.Eventually(t, fileContains(outputFile, "SECOND"), time.Second, time.Millisecond)
+	assert.Eventually(t, fileContains(reloadFile, "reload\nreload\n"), time.Second, time.Millisecond)
+
+	// Writes of other keys do not render the template again
+	set(session, testTableName, "OTHER", "VALUE", setOptions{})
+	time.Sleep(50 * time.Millisecond)
+	content, _ := ioutil.ReadFile(reloadFile)
+	assert.Equal(t, "reload\nreload\n", string(content))
+
+	close(stop)
+	assert.NoError(t, <-done)
+}
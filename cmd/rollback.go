@@ -0,0 +1,88 @@
+// Copyright © 2017 Jorge Dias <jorge@mrdias.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/diasjorge/dynamokv/models"
+	"github.com/spf13/cobra"
+)
+
+// rollbackCmd represents the rollback command
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback TABLENAME KEY --version VERSION",
+	Short: "Restore a previous version of a Key",
+	Long: `Restore a previous version of a Key. The restored value is written as a new version,
+so a rollback can itself be rolled back.`,
+	RunE: rollbackParse,
+}
+
+var rollbackVersion int64
+
+func init() {
+	RootCmd.AddCommand(rollbackCmd)
+	rollbackCmd.Flags().Int64VarP(&rollbackVersion, "version", "", 0, "Version to restore")
+}
+
+func rollbackParse(cmd *cobra.Command, args []string) error {
+	if len(args) != 2 {
+		return errors.New("TABLENAME KEY required")
+	}
+	if rollbackVersion <= 0 {
+		return fmt.Errorf("--version required\n%s", cmd.UsageString())
+	}
+
+	tableName, key := args[0], args[1]
+	if err := checkKey(key); err != nil {
+		return err
+	}
+
+	session := newSession(region, profile, endpointURL, namespaces)
+
+	return rollback(session, tableName, key, rollbackVersion)
+}
+
+func rollback(session *Session, tableName, key string, version int64) error {
+	table := newTable(session, tableName)
+	table.Source = fmt.Sprintf("rollback --version %d", version)
+	if err := checkTable(table); err != nil {
+		return err
+	}
+
+	entry, err := table.History().Get(key, version)
+	if err != nil {
+		return err
+	}
+
+	item := &models.Item{
+		Key:                  entry.Item.Key,
+		Value:                entry.Item.Value.Value,
+		Serialization:        entry.Item.Value.Serialization.Type,
+		SerializationOptions: entry.Item.Value.Serialization.Options,
+	}
+	if err := table.Set(item); err != nil {
+		return err
+	}
+	fmt.Printf("Restored version %d of %s as version %d\n", version, key, item.Version)
+	return nil
+}
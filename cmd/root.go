@@ -21,8 +21,12 @@
 package cmd
 
 import (
+	"fmt"
 	"os"
+	"strings"
 
+	"github.com/diasjorge/dynamokv/models"
+	"github.com/diasjorge/dynamokv/table"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -40,6 +44,12 @@ and load them as environment variables`,
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
 	if err := RootCmd.Execute(); err != nil {
+		if e, ok := err.(exitError); ok {
+			os.Exit(e.code)
+		}
+		if _, ok := err.(*table.ConflictError); ok {
+			os.Exit(conflictExitCode)
+		}
 		os.Exit(-1)
 	}
 }
@@ -54,12 +64,27 @@ func init() {
 	RootCmd.PersistentFlags().StringVar(&endpointURL, "endpoint-url", "", "dynamodb endpoint url")
 	RootCmd.PersistentFlags().StringVar(&region, "region", "", "AWS Region")
 	RootCmd.PersistentFlags().StringVar(&profile, "profile", "", "AWS Profile")
+	RootCmd.PersistentFlags().StringArrayVar(&namespaces, "namespace", nil, "Namespace of the keys, e.g. prod/api. fetch accepts several, later ones override earlier ones")
+	RootCmd.PersistentPreRunE = checkNamespaces
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
 	// RootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 }
 
+// checkNamespaces rejects several namespaces for commands other than fetch.
+func checkNamespaces(cmd *cobra.Command, args []string) error {
+	if len(namespaces) > 1 && cmd != fetchCmd {
+		return fmt.Errorf("Only fetch accepts several --namespace")
+	}
+	for _, namespace := range namespaces {
+		if namespace == "" || strings.Contains(namespace, models.NamespaceSeparator) {
+			return fmt.Errorf("Invalid namespace \"%s\"", namespace)
+		}
+	}
+	return nil
+}
+
 // initConfig reads in config file and ENV variables if set.
 func initConfig() {
 	viper.AutomaticEnv() // read in environment variables that match
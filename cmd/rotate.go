@@ -0,0 +1,117 @@
+// Copyright © 2017 Jorge Dias <jorge@mrdias.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/diasjorge/dynamokv/serializer"
+	"github.com/spf13/cobra"
+)
+
+// rotateCmd represents the rotate command
+var rotateCmd = &cobra.Command{
+	Use:   "rotate TABLENAME --from-key KEY --to-key KEY",
+	Short: "Re-encrypt values under a new KMS key",
+	Long: `Re-encrypt every value encrypted with one KMS key under another KMS key.
+Each value is written as soon as it is re-encrypted, so an interrupted rotation
+can be resumed by running the same command again.
+
+Keys are compared by ARN, so an alias matches values stored with its key id or
+ARN. Encrypted values whose key can't be determined are listed and the command
+fails after rotating the others.`,
+	RunE: rotateParse,
+}
+
+var fromKey, toKey string
+var dryRun bool
+
+func init() {
+	RootCmd.AddCommand(rotateCmd)
+	rotateCmd.Flags().StringVarP(&fromKey, "from-key", "", "", "KMS key currently used, as an id, ARN or alias")
+	rotateCmd.Flags().StringVarP(&toKey, "to-key", "", "", "KMS key to encrypt with")
+	rotateCmd.Flags().BoolVarP(&dryRun, "dry-run", "", false, "Only show the keys that would be rotated")
+}
+
+func rotateParse(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.New("TABLENAME required")
+	}
+	if fromKey == "" || toKey == "" {
+		return fmt.Errorf("--from-key and --to-key are required\n%s", cmd.UsageString())
+	}
+
+	tableName := args[0]
+
+	session := newSession(region, profile, endpointURL, namespaces)
+
+	return rotate(session, tableName, fromKey, toKey, dryRun)
+}
+
+func rotate(session *Session, tableName, fromKey, toKey string, dryRun bool) error {
+	table := newTable(session, tableName)
+	table.Source = "rotate"
+	if err := checkTable(table); err != nil {
+		return err
+	}
+
+	parsedItems, err := table.Read()
+	if err != nil {
+		return err
+	}
+
+	serializerContext := newSerializerContext(session, tableName)
+	matcher, err := serializer.NewKeyMatcher(serializerContext, fromKey)
+	if err != nil {
+		return err
+	}
+	skipped := []string{}
+	for _, parsedItem := range parsedItems {
+		matches, err := matcher.Matches(parsedItem)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Skipped %s: %v\n", parsedItem.Key, err)
+			skipped = append(skipped, parsedItem.Key)
+			continue
+		}
+		if !matches {
+			continue
+		}
+		if dryRun {
+			fmt.Printf("Would rotate %s\n", parsedItem.Key)
+			continue
+		}
+		item, err := serializer.ReEncryptItem(serializerContext, parsedItem, toKey)
+		if err != nil {
+			return fmt.Errorf("rotating %s: %v", parsedItem.Key, err)
+		}
+		if err := table.Set(item); err != nil {
+			return fmt.Errorf("rotating %s: %v", parsedItem.Key, err)
+		}
+		fmt.Printf("Rotated %s\n", parsedItem.Key)
+	}
+	if len(skipped) > 0 {
+		return fmt.Errorf("Could not determine the KMS key of %d encrypted item(s): %s", len(skipped), strings.Join(skipped, ", "))
+	}
+	return nil
+}
@@ -26,7 +26,6 @@ import (
 
 	"github.com/diasjorge/dynamokv/models"
 	"github.com/diasjorge/dynamokv/serializer"
-	"github.com/diasjorge/dynamokv/table"
 	"github.com/spf13/cobra"
 )
 
@@ -52,11 +51,11 @@ func (serialization *serializationFlag) Type() string {
 }
 
 func (serialization *serializationFlag) Set(value string) error {
-	// "kms::key:alias/value"
-	typeOptions := strings.Split(value, "::")
+	// "kms::key:alias/value" or "gzip,kms::key:alias/value"
+	typeOptions := strings.SplitN(value, "::", 2)
 
 	if len(typeOptions) < 1 {
-		return fmt.Errorf("invalid serialization format. Expected: type::option:optionValue,option2:optionValue2")
+		return fmt.Errorf("invalid serialization format. Expected: type[,type2]::option:optionValue,option2:optionValue2")
 	}
 
 	serialization.stype = typeOptions[0]
@@ -65,9 +64,9 @@ func (serialization *serializationFlag) Set(value string) error {
 		options := strings.Split(typeOptions[1], ",")
 		serialization.options = map[string]string{}
 		for _, option := range options {
-			keyVal := strings.Split(option, ":")
+			keyVal := strings.SplitN(option, ":", 2)
 			if len(keyVal) != 2 {
-				return fmt.Errorf("invalid serialization format. Expected: type::option:optionValue,option2:optionValue2")
+				return fmt.Errorf("invalid serialization format. Expected: type[,type2]::option:optionValue,option2:optionValue2")
 			}
 			serialization.options[keyVal[0]] = keyVal[1]
 		}
@@ -77,9 +76,27 @@ func (serialization *serializationFlag) Set(value string) error {
 
 var serializationF serializationFlag
 
+// setOptions controls how set writes the key.
+type setOptions struct {
+	serializationType    string
+	serializationOptions map[string]string
+	// ifVersion only writes the key if its stored version is ifVersion. It
+	// is not checked when 0.
+	ifVersion int64
+	// ifAbsent only writes the key if it does not exist.
+	ifAbsent bool
+	// noCreate fails if the table does not exist instead of creating it.
+	noCreate bool
+}
+
+var setOpts setOptions
+
 func init() {
 	RootCmd.AddCommand(setCmd)
-	setCmd.Flags().VarP(&serializationF, "serialization", "", "type::option:optionValue,*")
+	setCmd.Flags().VarP(&serializationF, "serialization", "", "type[,type2]::option:optionValue,*")
+	setCmd.Flags().Int64Var(&setOpts.ifVersion, "if-version", 0, "Only write if the stored version of the key is N")
+	setCmd.Flags().BoolVar(&setOpts.ifAbsent, "if-absent", false, "Only write if the key does not exist")
+	setCmd.Flags().BoolVar(&setOpts.noCreate, "no-create", false, "Fail if the table does not exist instead of creating it")
 }
 
 func setParse(cmd *cobra.Command, args []string) error {
@@ -87,37 +104,51 @@ func setParse(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("Invalid arguments\n%s", cmd.UsageString())
 	}
 	tableName, key, value := args[0], args[1], args[2]
+	if err := checkKey(key); err != nil {
+		return err
+	}
 
-	if serializationF.stype != "" {
+	if setOpts.ifAbsent && cmd.Flags().Changed("if-version") {
+		return fmt.Errorf("--if-version and --if-absent can't be used together")
+	}
+	if cmd.Flags().Changed("if-version") && setOpts.ifVersion < 1 {
+		return fmt.Errorf("--if-version must be at least 1")
 	}
 
-	session := newSession(region, profile, endpointURL)
+	session := newSession(region, profile, endpointURL, namespaces)
 
-	return set(session, tableName, key, value, serializationF.stype, serializationF.options)
+	options := setOpts
+	options.serializationType = serializationF.stype
+	options.serializationOptions = serializationF.options
+	return set(session, tableName, key, value, options)
 }
 
-func set(session *Session, tableName, key, value, serializationType string, serializationOptions map[string]string) error {
+// set stores a single key.
+func set(session *Session, tableName, key, value string, options setOptions) error {
 	parsedItem := models.NewParsedItem()
 	parsedItem.Key = key
 	parsedItem.Value.Value = value
-	if serializationType != "" {
-		parsedItem.Value.Serialization.Type = serializationType
-		parsedItem.Value.Serialization.Options = serializationOptions
+	if options.serializationType != "" {
+		parsedItem.Value.Serialization.Type = options.serializationType
+		parsedItem.Value.Serialization.Options = options.serializationOptions
 	}
 
-	item, err := serializer.SerializeItem(session.KMS, parsedItem)
+	item, err := serializer.SerializeItem(newSerializerContext(session, tableName), parsedItem)
 	if err != nil {
 		return err
 	}
 
-	table := table.NewTable(session.DynamoDB, tableName)
-	if err := table.Create(); err != nil {
+	table := newTable(session, tableName)
+	table.Source = "set"
+	if err := createTable(table, options.noCreate); err != nil {
 		return err
 	}
 
-	err = table.Set(item)
-	if err != nil {
-		return err
+	switch {
+	case options.ifAbsent:
+		return table.SetIfVersion(item, 0)
+	case options.ifVersion > 0:
+		return table.SetIfVersion(item, options.ifVersion)
 	}
-	return nil
+	return table.Set(item)
 }
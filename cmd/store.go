@@ -21,6 +21,14 @@
 package cmd
 
 import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/diasjorge/dynamokv/models"
 	"github.com/diasjorge/dynamokv/parser"
 	"github.com/diasjorge/dynamokv/serializer"
 	"github.com/diasjorge/dynamokv/table"
@@ -49,12 +57,45 @@ ENCRYPTED_KEY:
 READ_FILE_KEY:
   value:
     file: 'config'
+COMPRESSED_ENCRYPTED_KEY:
+  serialization:
+    - gzip
+    - type: kms
+      options:
+        key: 'alias/key'
+  value:
+    file: 'certificate.pem'
 `,
 	RunE: storeParse,
 }
 
+// storeOptions controls how store synchronizes the table with the file.
+type storeOptions struct {
+	// prune deletes keys that are in the table but not in the file.
+	prune  bool
+	dryRun bool
+	// force allows pruning more keys than maxDelete or maxDeletePercent.
+	force            bool
+	maxDelete        int
+	maxDeletePercent float64
+	// snapshot is the output of fetch --format json. When set, store aborts
+	// if any key changed since the snapshot was taken.
+	snapshot string
+	// noCreate fails if the table does not exist instead of creating it.
+	noCreate bool
+}
+
+var storeOpts storeOptions
+
 func init() {
 	RootCmd.AddCommand(storeCmd)
+	storeCmd.Flags().BoolVarP(&storeOpts.prune, "prune", "", false, "Delete keys not present in the configuration file")
+	storeCmd.Flags().BoolVarP(&storeOpts.dryRun, "dry-run", "", false, "Only show the changes that would be made")
+	storeCmd.Flags().BoolVarP(&storeOpts.force, "force", "", false, "Prune even when exceeding --max-delete or --max-delete-percent")
+	storeCmd.Flags().IntVarP(&storeOpts.maxDelete, "max-delete", "", 10, "Maximum number of keys to prune without --force")
+	storeCmd.Flags().Float64VarP(&storeOpts.maxDeletePercent, "max-delete-percent", "", 50, "Maximum percentage of the table to prune without --force")
+	storeCmd.Flags().StringVarP(&storeOpts.snapshot, "snapshot", "", "", "Abort if any key changed since this fetch --format json output")
+	storeCmd.Flags().BoolVarP(&storeOpts.noCreate, "no-create", "", false, "Fail if the table does not exist instead of creating it")
 }
 
 func storeParse(cmd *cobra.Command, args []string) error {
@@ -65,28 +106,175 @@ func storeParse(cmd *cobra.Command, args []string) error {
 	tableName := args[0]
 	configFile := args[1]
 
-	session := newSession(region, profile, endpointURL)
+	session := newSession(region, profile, endpointURL, namespaces)
 
-	return store(session, tableName, configFile)
+	return store(session, tableName, configFile, storeOpts)
 }
 
-func store(session *Session, tableName, configFile string) error {
+func store(session *Session, tableName, configFile string, options storeOptions) error {
 	parsedItems, err := parser.Parse(configFile)
 	if err != nil {
 		return err
 	}
+	for _, parsedItem := range parsedItems {
+		if err := checkKey(parsedItem.Key); err != nil {
+			return err
+		}
+	}
 
-	items, err := serializer.SerializeItems(session.KMS, parsedItems)
+	items, err := serializer.SerializeItems(newSerializerContext(session, tableName), parsedItems)
 	if err != nil {
 		return err
 	}
 
-	table := table.NewTable(session.DynamoDB, tableName)
-	if err := table.Create(); err != nil {
+	table := newTable(session, tableName)
+	table.Source = "store " + configFile
+
+	var expected map[string]int64
+	if options.snapshot != "" {
+		expected, err = checkSnapshot(table, options.snapshot, items)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Keys to prune are chosen, and the limits checked, against the table as
+	// it is before writing so that dry runs and real runs agree.
+	var stale []string
+	if options.prune {
+		stale, err = staleKeys(table, items, options)
+		if err != nil {
+			return err
+		}
+	}
+
+	if options.dryRun {
+		for _, item := range items {
+			fmt.Printf("Would write %s\n", item.Key)
+		}
+		for _, key := range stale {
+			fmt.Printf("Would delete %s\n", key)
+		}
+		return nil
+	}
+
+	if err := createTable(table, options.noCreate); err != nil {
+		return err
+	}
+	if expected != nil {
+		err = table.WriteIfVersion(items, expected)
+	} else {
+		err = table.Write(items)
+	}
+	if err != nil {
 		return err
 	}
-	if err := table.Write(items); err != nil {
+
+	return prune(table, stale)
+}
+
+// checkSnapshot compares the versions in the snapshot file with the table and
+// returns a ConflictError listing the keys that were added, removed or
+// changed since. Otherwise it returns the versions items are expected to have
+// when written.
+func checkSnapshot(t *table.Table, snapshotFile string, items []*models.Item) (map[string]int64, error) {
+	data, err := ioutil.ReadFile(snapshotFile)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := map[string]struct {
+		Version int64 `json:"version"`
+	}{}
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("Invalid snapshot %s: %s", snapshotFile, err)
+	}
+
+	parsedItems, err := t.Read()
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != dynamodb.ErrCodeResourceNotFoundException {
+			return nil, err
+		}
+	}
+
+	conflicts := []string{}
+	current := map[string]bool{}
+	for _, parsedItem := range parsedItems {
+		current[parsedItem.Key] = true
+		if entry, ok := snapshot[parsedItem.Key]; !ok || entry.Version != parsedItem.Version {
+			conflicts = append(conflicts, parsedItem.Key)
+		}
+	}
+	for key := range snapshot {
+		if !current[key] {
+			conflicts = append(conflicts, key)
+		}
+	}
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return nil, &table.ConflictError{Keys: conflicts}
+	}
+
+	expected := map[string]int64{}
+	for _, item := range items {
+		entry, ok := snapshot[item.Key]
+		switch {
+		case !ok:
+			expected[item.Key] = 0
+		case entry.Version == 0:
+			// Items written before versioning count as version 1.
+			expected[item.Key] = 1
+		default:
+			expected[item.Key] = entry.Version
+		}
+	}
+	return expected, nil
+}
+
+// staleKeys returns the keys of the table that are not in items. It fails if
+// they exceed the limits in options, unless options.force is set.
+func staleKeys(table *table.Table, items []*models.Item, options storeOptions) ([]string, error) {
+	parsedItems, err := table.Read()
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeResourceNotFoundException {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	keep := map[string]bool{}
+	for _, item := range items {
+		keep[item.Key] = true
+	}
+	stale := []string{}
+	for _, parsedItem := range parsedItems {
+		if !keep[parsedItem.Key] {
+			stale = append(stale, parsedItem.Key)
+		}
+	}
+	sort.Strings(stale)
+
+	if len(stale) == 0 {
+		return nil, nil
+	}
+
+	percent := 100 * float64(len(stale)) / float64(len(parsedItems))
+	if !options.force && (len(stale) > options.maxDelete || percent > options.maxDeletePercent) {
+		return nil, fmt.Errorf("Refusing to prune %d keys (%.0f%% of the table): limits are %d keys and %.0f%%. Use --force to prune anyway",
+			len(stale), percent, options.maxDelete, options.maxDeletePercent)
+	}
+	return stale, nil
+}
+
+// prune deletes the stale keys.
+func prune(table *table.Table, stale []string) error {
+	if len(stale) == 0 {
+		return nil
+	}
+	if err := table.BatchDelete(stale); err != nil {
 		return err
 	}
+	for _, key := range stale {
+		fmt.Printf("Deleted %s\n", key)
+	}
 	return nil
 }
@@ -0,0 +1,257 @@
+// Copyright © 2017 Jorge Dias <jorge@mrdias.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/diasjorge/dynamokv/models"
+	"github.com/diasjorge/dynamokv/table"
+	"github.com/spf13/cobra"
+)
+
+// tableCmd groups the commands managing tables
+var tableCmd = &cobra.Command{
+	Use:   "table",
+	Short: "Manage dynamokv tables",
+	Long:  "Manage the AWS DynamoDB tables used by dynamokv and their history tables.",
+}
+
+// tableCreateCmd represents the table create command
+var tableCreateCmd = &cobra.Command{
+	Use:   "create TABLENAME",
+	Short: "Create a table",
+	Long: `Create a table and its history table. Tables use on-demand billing unless
+--billing-mode PROVISIONED or a capacity is given. Existing tables are not modified.`,
+	RunE: tableCreateParse,
+}
+
+// tableDescribeCmd represents the table describe command
+var tableDescribeCmd = &cobra.Command{
+	Use:   "describe TABLENAME",
+	Short: "Describe a table",
+	Long:  "Show the item count, schema version and settings of a table.",
+	RunE:  tableDescribeParse,
+}
+
+// tableListCmd represents the table list command
+var tableListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List tables",
+	Long: `List the tables managed by dynamokv, found through the ` + table.SchemaVersionTag + ` tag.
+Tables created by older versions of dynamokv are listed once migrated.`,
+	RunE: tableListParse,
+}
+
+// tableDeleteCmd represents the table delete command
+var tableDeleteCmd = &cobra.Command{
+	Use:   "delete TABLENAME",
+	Short: "Delete a table",
+	Long:  "Delete a table and its history table.",
+	RunE:  tableDeleteParse,
+}
+
+// tableMigrateCmd represents the table migrate command
+var tableMigrateCmd = &cobra.Command{
+	Use:   "migrate TABLENAME",
+	Short: "Upgrade a table",
+	Long: `Upgrade a table created by an older version of dynamokv to the current schema version.
+Keys containing "::" written before namespaces are listed, as they are now read as keys
+of a namespace.`,
+	RunE: tableMigrateParse,
+}
+
+var createOpts = table.CreateOptions{}
+
+func init() {
+	RootCmd.AddCommand(tableCmd)
+	tableCmd.AddCommand(tableCreateCmd, tableDescribeCmd, tableListCmd, tableDeleteCmd, tableMigrateCmd)
+	tableCreateCmd.Flags().StringVarP(&createOpts.BillingMode, "billing-mode", "", dynamodb.BillingModePayPerRequest, "Billing mode: PAY_PER_REQUEST or PROVISIONED")
+	tableCreateCmd.Flags().Int64VarP(&createOpts.ReadCapacity, "read-capacity", "", 0, "Read capacity units, implies PROVISIONED billing")
+	tableCreateCmd.Flags().Int64VarP(&createOpts.WriteCapacity, "write-capacity", "", 0, "Write capacity units, implies PROVISIONED billing")
+	tableCreateCmd.Flags().StringVarP(&createOpts.KMSKey, "sse-kms-key", "", "", "Encrypt the table with this customer managed KMS key")
+	tableCreateCmd.Flags().StringToStringVarP(&createOpts.Tags, "tag", "", nil, "Resource tag as key=value, can be repeated")
+	tableCreateCmd.Flags().BoolVarP(&createOpts.PointInTimeRecovery, "point-in-time-recovery", "", false, "Enable point-in-time recovery")
+	tableCreateCmd.Flags().BoolVarP(&createOpts.DeletionProtection, "deletion-protection", "", false, "Enable deletion protection")
+	tableDeleteCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Do not ask for confirmation")
+}
+
+func tableCreateParse(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.New("TABLENAME required")
+	}
+
+	tableName := args[0]
+
+	options := createOpts
+	if !cmd.Flags().Changed("billing-mode") && (options.ReadCapacity != 0 || options.WriteCapacity != 0) {
+		options.BillingMode = dynamodb.BillingModeProvisioned
+	}
+
+	session := newSession(region, profile, endpointURL, namespaces)
+
+	return tableCreate(session, tableName, &options)
+}
+
+func tableCreate(session *Session, tableName string, options *table.CreateOptions) error {
+	table := openTable(session, tableName)
+	exists, err := table.Exists()
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("Table %s already exists", tableName)
+	}
+	if err := table.CreateWithOptions(options); err != nil {
+		return err
+	}
+	fmt.Printf("Created table %s\n", tableName)
+	return nil
+}
+
+func tableDescribeParse(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.New("TABLENAME required")
+	}
+
+	session := newSession(region, profile, endpointURL, namespaces)
+
+	return tableDescribe(session, args[0])
+}
+
+func tableDescribe(session *Session, tableName string) error {
+	description, err := openTable(session, tableName).Describe()
+	if err != nil {
+		return err
+	}
+
+	billing := description.BillingMode
+	if description.BillingMode == dynamodb.BillingModeProvisioned {
+		billing = fmt.Sprintf("%s (%d RCU, %d WCU)", billing, description.ReadCapacity, description.WriteCapacity)
+	}
+	schema := strconv.Itoa(description.SchemaVersion)
+	if description.SchemaVersion < table.SchemaVersion {
+		schema += fmt.Sprintf(" (current is %d, run dynamokv table migrate)", table.SchemaVersion)
+	}
+	tags := []string{}
+	for key, value := range description.Tags {
+		tags = append(tags, key+"="+value)
+	}
+	sort.Strings(tags)
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(writer, "Name:\t%s\n", description.Name)
+	fmt.Fprintf(writer, "Status:\t%s\n", description.Status)
+	fmt.Fprintf(writer, "Items:\t%d (%d bytes)\n", description.ItemCount, description.SizeBytes)
+	fmt.Fprintf(writer, "Schema version:\t%s\n", schema)
+	fmt.Fprintf(writer, "History table:\t%t\n", description.HasHistory)
+	fmt.Fprintf(writer, "Billing mode:\t%s\n", billing)
+	fmt.Fprintf(writer, "SSE KMS key:\t%s\n", valueOrDash(description.KMSKey))
+	fmt.Fprintf(writer, "Point-in-time recovery:\t%t\n", description.PointInTimeRecovery)
+	fmt.Fprintf(writer, "Deletion protection:\t%t\n", description.DeletionProtection)
+	fmt.Fprintf(writer, "Tags:\t%s\n", valueOrDash(strings.Join(tags, ", ")))
+	return writer.Flush()
+}
+
+func tableListParse(cmd *cobra.Command, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("Invalid arguments\n%s", cmd.UsageString())
+	}
+
+	session := newSession(region, profile, endpointURL, namespaces)
+
+	return tableList(session)
+}
+
+func tableList(session *Session) error {
+	names, err := table.List(session.DynamoDB)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		fmt.Println(name)
+	}
+	return nil
+}
+
+func tableDeleteParse(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.New("TABLENAME required")
+	}
+
+	session := newSession(region, profile, endpointURL, namespaces)
+
+	return tableDelete(session, args[0], assumeYes)
+}
+
+func tableDelete(session *Session, tableName string, assumeYes bool) error {
+	if !assumeYes && !confirm(fmt.Sprintf("The tables %s and %s will be deleted with all their items.\n", tableName, tableName+table.HistorySuffix)) {
+		return errors.New("Aborted")
+	}
+	if err := openTable(session, tableName).DeleteTables(); err != nil {
+		return err
+	}
+	fmt.Printf("Deleted table %s\n", tableName)
+	return nil
+}
+
+func tableMigrateParse(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.New("TABLENAME required")
+	}
+
+	session := newSession(region, profile, endpointURL, namespaces)
+
+	return tableMigrate(session, args[0])
+}
+
+func tableMigrate(session *Session, tableName string) error {
+	t := openTable(session, tableName)
+	applied, err := t.Migrate()
+	for _, description := range applied {
+		fmt.Printf("Applied: %s\n", description)
+	}
+	if err != nil {
+		return err
+	}
+	if len(applied) == 0 {
+		fmt.Printf("Table %s is up to date\n", tableName)
+		return nil
+	}
+
+	// Keys written before namespaces may contain the separator, and are now
+	// only read with the --namespace before it.
+	keys, err := t.SeparatorKeys()
+	if err != nil {
+		return err
+	}
+	if len(keys) > 0 {
+		fmt.Printf("Keys containing %q are read as NAMESPACE%sKEY and hidden without --namespace: %s\n", models.NamespaceSeparator, models.NamespaceSeparator, strings.Join(keys, ", "))
+	}
+	return nil
+}
@@ -21,15 +21,24 @@
 package cmd
 
 import (
-	"errors"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
 	"fmt"
 	"io/ioutil"
-	"log"
+	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
+	"github.com/diasjorge/dynamokv/models"
 	"github.com/diasjorge/dynamokv/serializer"
-	"github.com/diasjorge/dynamokv/table"
 	"github.com/spf13/cobra"
 )
 
@@ -44,16 +53,85 @@ Placeholders accept the following modifiers:
   {{Key}}
   Example: "{{Username}}" will be replaced by the value of the "Username" Key.
   {{RAW:Key}}
-  Example: "{{RAW:Username}}" will be replaced by the value of the "Username" Key without applying deserialization.`,
+  Example: "{{RAW:Username}}" will be replaced by the value of the "Username" Key without applying deserialization.
+  {{OPT:Key}}
+  Example: "{{OPT:Username}}" will be replaced by an empty string if the "Username" Key does not exist.
+  {{Key|default text}}
+  Example: "{{Username|admin}}" will be replaced by "admin" if the "Username" Key does not exist.
+  {{JSON:Key}}, {{YAML:Key}}, {{SHELL:Key}}, {{URL:Key}}, {{XML:Key}} and {{B64:Key}}
+  Example: "{{JSON:Password}}" will be replaced by the value of the "Password" Key as a quoted JSON string.
+  JSON, YAML and SHELL add the quotes, URL escapes query strings, XML escapes
+  text and attributes and B64 encodes in base64.
+
+Modifiers can be chained with commas and escapers are applied in order, e.g.
+"{{RAW,B64:Key}}" or "{{OPT,JSON:Key}}".
+
+With --strict, missing keys are errors even with OPT or a default value.
+
+With --engine go, or for files with the .gotmpl extension, the template is
+rendered with Go's text/template instead. It supports conditionals and loops
+and provides the functions:
+  kv "Key"             the value of Key, empty if it does not exist
+  raw "Key"            the value of Key without applying deserialization
+  keys "PREFIX"        the sorted keys starting with PREFIX
+  default "d" VALUE    d if VALUE is empty
+  required "msg" VALUE fails with msg if VALUE is empty
+  b64enc, toJson
+  quote                single quoted for shells and env files
+  indent N, nindent N  indent every line, nindent starts a new line first
+  Example: "{{range keys "DB_"}}{{.}}={{kv . | quote}}{{end}}"
+
+With --watch the command keeps running, checks the keys every --interval and
+renders OUTPUTFILE again when they change. Every time the output changes the
+--exec command runs, once no other change happened for --debounce.`,
 	RunE: templateParse,
 }
 
 func init() {
 	RootCmd.AddCommand(templateCmd)
 	templateCmd.Flags().BoolVarP(&inplace, "inplace", "i", false, "Replace template file inline")
+	templateCmd.Flags().StringVarP(&templateOpts.engine, "engine", "", "", "Template engine: legacy or go. Defaults to go for "+goTemplateExtension+" files")
+	templateCmd.Flags().BoolVarP(&templateOpts.strict, "strict", "", false, "Fail on missing keys even with OPT or a default value")
+	templateCmd.Flags().BoolVarP(&templateOpts.watch, "watch", "", false, "Keep running and render the template again when a key changes")
+	templateCmd.Flags().DurationVarP(&templateOpts.interval, "interval", "", 30*time.Second, "Interval between checks for changes with --watch")
+	templateCmd.Flags().DurationVarP(&templateOpts.debounce, "debounce", "", 5*time.Second, "Time without changes before running --exec")
+	templateCmd.Flags().StringVarP(&templateOpts.exec, "exec", "", "", "Shell command run after the output changes with --watch, e.g. \"systemctl reload nginx\"")
+}
+
+const (
+	modRaw      = "RAW"
+	modOptional = "OPT"
+)
+
+// defaultSeparator separates the key from its default value in a
+// placeholder, e.g. "{{Username|admin}}".
+const defaultSeparator = "|"
+
+const (
+	engineLegacy = "legacy"
+	engineGo     = "go"
+)
+
+// goTemplateExtension selects the go engine when --engine is not given.
+const goTemplateExtension = ".gotmpl"
+
+// templateOptions controls how templates are rendered.
+type templateOptions struct {
+	// engine is engineLegacy or engineGo. When empty it is chosen by the
+	// extension of the template file.
+	engine string
+	// strict makes missing keys errors in legacy templates even with OPT or
+	// a default value.
+	strict bool
+	// watch keeps rendering the template every interval in which a key it
+	// references changed, running exec debounce after the last change.
+	watch    bool
+	interval time.Duration
+	debounce time.Duration
+	exec     string
 }
 
-const modRaw = "RAW"
+var templateOpts templateOptions
 
 var inplace bool
 
@@ -77,27 +155,43 @@ func templateParse(cmd *cobra.Command, args []string) error {
 		outputFile = args[2]
 	}
 
-	session := newSession(region, profile, endpointURL)
+	if templateOpts.watch {
+		if outputFile == "" || inplace {
+			return fmt.Errorf("--watch requires OUTPUTFILE\n%s", cmd.UsageString())
+		}
+	} else if cmd.Flags().Changed("exec") {
+		return fmt.Errorf("--exec requires --watch\n%s", cmd.UsageString())
+	}
+
+	session := newSession(region, profile, endpointURL, namespaces)
 
-	return template(session, tableName, templateFile, outputFile)
+	if templateOpts.watch {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+		stop := make(chan struct{})
+		go func() {
+			<-signals
+			close(stop)
+		}()
+		return watch(session, tableName, templateFile, outputFile, templateOpts, stop)
+	}
+
+	return template(session, tableName, templateFile, outputFile, templateOpts)
 }
 
-func template(session *Session, tableName, templateFile, outputFile string) error {
+func template(session *Session, tableName, templateFile, outputFile string, options templateOptions) error {
 	template, err := ioutil.ReadFile(templateFile)
 	if err != nil {
 		return err
 	}
 
-	var replaceErrors = &[]error{}
-	re := regexp.MustCompile(`{{(\w+?:)?.+?}}`)
-	output := re.ReplaceAllFunc(template, generateReplaceFunc(session, tableName, replaceErrors))
-
-	if len(*replaceErrors) > 0 {
-		return errors.New("Processing template error")
+	output, err := render(session, tableName, templateFile, template, options)
+	if err != nil {
+		return err
 	}
 
 	if outputFile != "" {
-		err := ioutil.WriteFile(outputFile, output, 0644)
+		err := writeFileAtomic(outputFile, output, 0644)
 		if err != nil {
 			return err
 		}
@@ -107,32 +201,246 @@ func template(session *Session, tableName, templateFile, outputFile string) erro
 	return nil
 }
 
-func generateReplaceFunc(session *Session, tableName string, errors *[]error) func([]byte) []byte {
-	table := table.NewTable(session.DynamoDB, tableName)
-	logger := log.New(os.Stderr, "", 0)
+// templateEngine returns the engine used to render templateFile.
+func templateEngine(templateFile string, options templateOptions) string {
+	if options.engine != "" {
+		return options.engine
+	}
+	if filepath.Ext(templateFile) == goTemplateExtension {
+		return engineGo
+	}
+	return engineLegacy
+}
+
+func render(session *Session, tableName, templateFile string, template []byte, options templateOptions) ([]byte, error) {
+	switch engine := templateEngine(templateFile, options); engine {
+	case engineLegacy:
+		return renderLegacy(session, tableName, template, options.strict)
+	case engineGo:
+		return renderGo(session, tableName, filepath.Base(templateFile), template)
+	default:
+		return nil, fmt.Errorf("Unknown template engine %s. Expected %s or %s", engine, engineLegacy, engineGo)
+	}
+}
+
+// writeFileAtomic writes data to a temporary file next to filename and
+// renames it, so readers never see a partially written file.
+func writeFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
+
+// placeholderPattern matches the {{Key}} and {{MOD,MOD2:Key}} placeholders
+// of legacy templates.
+var placeholderPattern = regexp.MustCompile(`{{((?P<mod>[\w,]+?):)?(?P<key>.+?)}}`)
 
-	return func(input []byte) []byte {
-		re := regexp.MustCompile(`{{((?P<mod>\w+?):)?(?P<key>.+?)}}`)
-		matches := re.FindSubmatch(input)
+// modifierSeparator separates chained modifiers, e.g. "{{RAW,B64:Key}}".
+const modifierSeparator = ","
+
+// escapers escape values for the format of the output.
+var escapers = map[string]func(string) string{
+	"JSON":  quoteJSON,
+	"YAML":  quoteJSON, // YAML double quoted scalars accept JSON strings.
+	"SHELL": quoteShell,
+	"URL":   url.QueryEscape,
+	"XML":   escapeXML,
+	"B64": func(value string) string {
+		return base64.StdEncoding.EncodeToString([]byte(value))
+	},
+}
 
-		var key, mod []byte
-		for i, name := range re.SubexpNames() {
-			switch name {
-			case "mod":
-				mod = matches[i]
-			case "key":
-				key = matches[i]
+func quoteJSON(value string) string {
+	var output bytes.Buffer
+	encoder := json.NewEncoder(&output)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(value)
+	return strings.TrimSuffix(output.String(), "\n")
+}
+
+func quoteShell(value string) string {
+	return "'" + strings.Replace(value, "'", "'\\''", -1) + "'"
+}
+
+func escapeXML(value string) string {
+	var output bytes.Buffer
+	xml.EscapeText(&output, []byte(value))
+	return output.String()
+}
+
+// placeholder is an occurrence of a placeholder in a legacy template.
+type placeholder struct {
+	start, end   int
+	line         int
+	key          string
+	defaultValue string
+	hasDefault   bool
+	raw          bool
+	optional     bool
+	// escapers are applied in the order the modifiers are given.
+	escapers []func(string) string
+	// unknown lists the modifiers that are not supported.
+	unknown []string
+}
+
+func parsePlaceholders(template []byte) []*placeholder {
+	placeholders := []*placeholder{}
+	for _, match := range placeholderPattern.FindAllSubmatchIndex(template, -1) {
+		p := &placeholder{
+			start: match[0],
+			end:   match[1],
+			line:  bytes.Count(template[:match[0]], []byte("\n")) + 1,
+		}
+		key := string(template[match[6]:match[7]])
+		if index := strings.Index(key, defaultSeparator); index >= 0 {
+			key, p.defaultValue, p.hasDefault = key[:index], key[index+len(defaultSeparator):], true
+		}
+		p.key = key
+		if match[4] >= 0 {
+			for _, modifier := range strings.Split(string(template[match[4]:match[5]]), modifierSeparator) {
+				escaper, isEscaper := escapers[modifier]
+				switch {
+				case modifier == modRaw:
+					p.raw = true
+				case modifier == modOptional:
+					p.optional = true
+				case isEscaper:
+					p.escapers = append(p.escapers, escaper)
+				default:
+					p.unknown = append(p.unknown, modifier)
+				}
 			}
 		}
-		parsedItem, err := table.Get(string(key))
-		if err != nil {
-			logger.Fatal(err)
+		placeholders = append(placeholders, p)
+	}
+	return placeholders
+}
+
+// templateError lists every placeholder that could not be rendered.
+type templateError struct {
+	errors []string
+}
+
+func (e *templateError) Error() string {
+	return fmt.Sprintf("Processing template error:\n  %s", strings.Join(e.errors, "\n  "))
+}
+
+// renderLegacy replaces the placeholders of template. The keys are fetched at
+// once and deserialized concurrently, and a templateError lists every key
+// missing or failing to deserialize. Unless strict, missing keys with a
+// default value or the OPT modifier are not errors.
+func renderLegacy(session *Session, tableName string, template []byte, strict bool) ([]byte, error) {
+	placeholders := parsePlaceholders(template)
+
+	keys := []string{}
+	seen := map[string]bool{}
+	deserializeKeys := map[string]bool{}
+	for _, p := range placeholders {
+		if !seen[p.key] {
+			keys = append(keys, p.key)
+			seen[p.key] = true
 		}
-		deserialize := string(mod) != modRaw
-		item, err := serializer.DeserializeItem(session.KMS, parsedItem, deserialize)
-		if err != nil {
-			logger.Fatal(err)
+		if !p.raw {
+			deserializeKeys[p.key] = true
 		}
-		return []byte(item.Value)
 	}
+	parsedItems, err := openTable(session, tableName).GetItems(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	toDeserialize := []*models.ParsedItem{}
+	for key := range deserializeKeys {
+		if parsedItem, ok := parsedItems[key]; ok {
+			toDeserialize = append(toDeserialize, parsedItem)
+		}
+	}
+	values, valueErrors := deserializeValues(newSerializerContext(session, tableName), toDeserialize)
+
+	errors := []string{}
+	var output bytes.Buffer
+	last := 0
+	for _, p := range placeholders {
+		output.Write(template[last:p.start])
+		last = p.end
+
+		if len(p.unknown) > 0 {
+			errors = append(errors, fmt.Sprintf("line %d: Unknown modifier %s for Key \"%s\"", p.line, strings.Join(p.unknown, ", "), p.key))
+			continue
+		}
+
+		var value string
+		parsedItem, ok := parsedItems[p.key]
+		switch {
+		case !ok && !strict && p.hasDefault:
+			value = p.defaultValue
+		case !ok && !strict && p.optional:
+			// Optional placeholders of missing keys render empty.
+		case !ok:
+			errors = append(errors, fmt.Sprintf("line %d: Key \"%s\" not found", p.line, p.key))
+			continue
+		case p.raw:
+			value = parsedItem.Value.Value
+		case valueErrors[p.key] != nil:
+			errors = append(errors, fmt.Sprintf("line %d: Key \"%s\": %s", p.line, p.key, valueErrors[p.key]))
+			continue
+		default:
+			value = values[p.key]
+		}
+		for _, escape := range p.escapers {
+			value = escape(value)
+		}
+		output.WriteString(value)
+	}
+	output.Write(template[last:])
+
+	if len(errors) > 0 {
+		return nil, &templateError{errors: errors}
+	}
+	return output.Bytes(), nil
+}
+
+// templateConcurrency is the number of values deserialized in parallel.
+const templateConcurrency = 8
+
+// deserializeValues deserializes parsedItems concurrently and returns their
+// values and errors by Key.
+func deserializeValues(ctx *serializer.Context, parsedItems []*models.ParsedItem) (map[string]string, map[string]error) {
+	values := map[string]string{}
+	errors := map[string]error{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, templateConcurrency)
+	for _, parsedItem := range parsedItems {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(parsedItem *models.ParsedItem) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			item, err := serializer.DeserializeItem(ctx, parsedItem, true)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errors[parsedItem.Key] = err
+				return
+			}
+			values[parsedItem.Key] = item.Value
+		}(parsedItem)
+	}
+	wg.Wait()
+	return values, errors
 }
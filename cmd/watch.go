@@ -0,0 +1,202 @@
+// Copyright © 2017 Jorge Dias <jorge@mrdias.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sort"
+	"time"
+
+	"github.com/diasjorge/dynamokv/models"
+)
+
+// watcher renders a template again when the keys it references change.
+type watcher struct {
+	session      *Session
+	tableName    string
+	templateFile string
+	outputFile   string
+	template     []byte
+	options      templateOptions
+	// keys are the keys referenced by the template, or nil for every key of
+	// the table as go templates can read any key.
+	keys        []string
+	fingerprint string
+	// execTimer is set while the exec command waits for the debounce time.
+	execTimer *time.Timer
+}
+
+// watch renders templateFile into outputFile and checks the keys it
+// references every interval, rendering it again when they changed. Every
+// time the output changes the exec command is scheduled to run once no
+// change happened for debounce. It returns when stop is closed.
+func watch(session *Session, tableName, templateFile, outputFile string, options templateOptions, stop <-chan struct{}) error {
+	template, err := ioutil.ReadFile(templateFile)
+	if err != nil {
+		return err
+	}
+	w := &watcher{
+		session:      session,
+		tableName:    tableName,
+		templateFile: templateFile,
+		outputFile:   outputFile,
+		template:     template,
+		options:      options,
+	}
+	if templateEngine(templateFile, options) == engineLegacy {
+		w.keys = []string{}
+		seen := map[string]bool{}
+		for _, p := range parsePlaceholders(template) {
+			if !seen[p.key] {
+				w.keys = append(w.keys, p.key)
+				seen[p.key] = true
+			}
+		}
+	}
+
+	if err := w.check(); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(options.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			if w.execTimer != nil {
+				w.execTimer.Stop()
+			}
+			return nil
+		case <-ticker.C:
+			if err := w.check(); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		case <-w.execC():
+			w.execTimer = nil
+			w.runExec()
+		}
+	}
+}
+
+// check renders the template if the referenced keys changed since the last
+// render and writes the output if it is different.
+func (w *watcher) check() error {
+	fingerprint, err := w.keysFingerprint()
+	if err != nil {
+		return err
+	}
+	if fingerprint == w.fingerprint {
+		return nil
+	}
+
+	output, err := render(w.session, w.tableName, w.templateFile, w.template, w.options)
+	if err != nil {
+		return err
+	}
+	w.fingerprint = fingerprint
+
+	current, err := ioutil.ReadFile(w.outputFile)
+	if err == nil && bytes.Equal(current, output) {
+		return nil
+	}
+	if err := writeFileAtomic(w.outputFile, output, 0644); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Rendered %s\n", w.outputFile)
+
+	if w.options.exec != "" {
+		w.scheduleExec()
+	}
+	return nil
+}
+
+// keysFingerprint returns a hash of the referenced keys as stored.
+func (w *watcher) keysFingerprint() (string, error) {
+	table := openTable(w.session, w.tableName)
+	parsedItems := map[string]*models.ParsedItem{}
+	if w.keys == nil {
+		items, err := table.Read()
+		if err != nil {
+			return "", err
+		}
+		for _, item := range items {
+			parsedItems[item.Key] = item
+		}
+	} else {
+		var err error
+		parsedItems, err = table.GetItems(w.keys)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	keys := []string{}
+	for key := range parsedItems {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	hash := sha256.New()
+	for _, key := range keys {
+		parsedItem := parsedItems[key]
+		serialization := ""
+		if parsedItem.Value.Serialization != nil {
+			serialization = parsedItem.Value.Serialization.Type
+		}
+		fmt.Fprintf(hash, "%q %d %q %q\n", key, parsedItem.Version, serialization, parsedItem.Value.Value)
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
+
+// scheduleExec runs the exec command after the debounce time, postponing it
+// if it is already scheduled.
+func (w *watcher) scheduleExec() {
+	if w.execTimer == nil {
+		w.execTimer = time.NewTimer(w.options.debounce)
+		return
+	}
+	if !w.execTimer.Stop() {
+		<-w.execTimer.C
+	}
+	w.execTimer.Reset(w.options.debounce)
+}
+
+// execC returns the channel of execTimer, or nil, which blocks forever, if
+// the exec command is not scheduled.
+func (w *watcher) execC() <-chan time.Time {
+	if w.execTimer == nil {
+		return nil
+	}
+	return w.execTimer.C
+}
+
+func (w *watcher) runExec() {
+	command := exec.Command("sh", "-c", w.options.exec)
+	command.Stdout = os.Stdout
+	command.Stderr = os.Stderr
+	if err := command.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "Command \"%s\" failed: %s\n", w.options.exec, err)
+	}
+}
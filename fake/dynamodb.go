@@ -0,0 +1,616 @@
+package fake
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// DynamoDB is a fake DynamoDB client keeping tables in memory. It implements
+// the operations used by dynamokv; calling any other operation panics.
+type DynamoDB struct {
+	dynamodbiface.DynamoDBAPI
+
+	mu     sync.Mutex
+	tables map[string]*fakeTable
+
+	// UnprocessedWrites is the number of BatchWriteItem calls that will
+	// return every request as unprocessed, as when throttled.
+	UnprocessedWrites int
+	// ConflictingTransactions is the number of TransactWriteItems calls that
+	// will be canceled with a TransactionConflict, as when racing another
+	// transaction.
+	ConflictingTransactions int
+}
+
+type fakeTable struct {
+	description         *dynamodb.TableDescription
+	items               map[string]map[string]*dynamodb.AttributeValue
+	tags                []*dynamodb.Tag
+	pointInTimeRecovery bool
+}
+
+func NewDynamoDB() *DynamoDB {
+	return &DynamoDB{tables: map[string]*fakeTable{}}
+}
+
+func resourceNotFound(tableName *string) error {
+	return awserr.New(dynamodb.ErrCodeResourceNotFoundException, "Requested resource not found: Table: "+aws.StringValue(tableName)+" not found", nil)
+}
+
+func conditionalCheckFailed() error {
+	return awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil)
+}
+
+func (svc *DynamoDB) table(tableName *string) (*fakeTable, error) {
+	table, ok := svc.tables[aws.StringValue(tableName)]
+	if !ok {
+		return nil, resourceNotFound(tableName)
+	}
+	return table, nil
+}
+
+// itemID identifies an item by the values of its key attributes.
+func (table *fakeTable) itemID(item map[string]*dynamodb.AttributeValue) (string, error) {
+	parts := []string{}
+	for _, element := range table.description.KeySchema {
+		value, ok := item[*element.AttributeName]
+		if !ok {
+			return "", awserr.New("ValidationException", "Missing key attribute "+*element.AttributeName, nil)
+		}
+		parts = append(parts, attributeString(value))
+	}
+	return strings.Join(parts, "\x00"), nil
+}
+
+// sortedIDs returns the ids of all items ordered by their key attributes.
+func (table *fakeTable) sortedIDs() []string {
+	ids := []string{}
+	for id := range table.items {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		for _, element := range table.description.KeySchema {
+			a := table.items[ids[i]][*element.AttributeName]
+			b := table.items[ids[j]][*element.AttributeName]
+			if a.N != nil && b.N != nil {
+				x, _ := strconv.ParseFloat(*a.N, 64)
+				y, _ := strconv.ParseFloat(*b.N, 64)
+				if x != y {
+					return x < y
+				}
+				continue
+			}
+			if attributeString(a) != attributeString(b) {
+				return attributeString(a) < attributeString(b)
+			}
+		}
+		return false
+	})
+	return ids
+}
+
+func attributeString(value *dynamodb.AttributeValue) string {
+	switch {
+	case value.S != nil:
+		return *value.S
+	case value.N != nil:
+		return *value.N
+	}
+	return value.String()
+}
+
+func (svc *DynamoDB) CreateTable(input *dynamodb.CreateTableInput) (*dynamodb.CreateTableOutput, error) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	name := aws.StringValue(input.TableName)
+	if _, ok := svc.tables[name]; ok {
+		return nil, awserr.New(dynamodb.ErrCodeResourceInUseException, "Table already exists: "+name, nil)
+	}
+	description := &dynamodb.TableDescription{
+		TableName:            input.TableName,
+		TableArn:             aws.String("arn:aws:dynamodb:local:000000000000:table/" + name),
+		TableStatus:          aws.String(dynamodb.TableStatusActive),
+		KeySchema:            input.KeySchema,
+		AttributeDefinitions: input.AttributeDefinitions,
+		ItemCount:            aws.Int64(0),
+		BillingModeSummary: &dynamodb.BillingModeSummary{
+			BillingMode: aws.String(dynamodb.BillingModeProvisioned),
+		},
+		DeletionProtectionEnabled: aws.Bool(aws.BoolValue(input.DeletionProtectionEnabled)),
+	}
+	if input.BillingMode != nil {
+		description.BillingModeSummary.BillingMode = input.BillingMode
+	}
+	if input.ProvisionedThroughput != nil {
+		description.ProvisionedThroughput = &dynamodb.ProvisionedThroughputDescription{
+			ReadCapacityUnits:  input.ProvisionedThroughput.ReadCapacityUnits,
+			WriteCapacityUnits: input.ProvisionedThroughput.WriteCapacityUnits,
+		}
+	}
+	if input.SSESpecification != nil && aws.BoolValue(input.SSESpecification.Enabled) {
+		description.SSEDescription = &dynamodb.SSEDescription{
+			Status:          aws.String(dynamodb.SSEStatusEnabled),
+			SSEType:         input.SSESpecification.SSEType,
+			KMSMasterKeyArn: input.SSESpecification.KMSMasterKeyId,
+		}
+	}
+	svc.tables[name] = &fakeTable{
+		description: description,
+		items:       map[string]map[string]*dynamodb.AttributeValue{},
+		tags:        input.Tags,
+	}
+	return &dynamodb.CreateTableOutput{TableDescription: description}, nil
+}
+
+func (svc *DynamoDB) DescribeTable(input *dynamodb.DescribeTableInput) (*dynamodb.DescribeTableOutput, error) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	table, err := svc.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	table.description.ItemCount = aws.Int64(int64(len(table.items)))
+	return &dynamodb.DescribeTableOutput{Table: table.description}, nil
+}
+
+func (svc *DynamoDB) DeleteTable(input *dynamodb.DeleteTableInput) (*dynamodb.DeleteTableOutput, error) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	table, err := svc.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	if aws.BoolValue(table.description.DeletionProtectionEnabled) {
+		return nil, awserr.New("ValidationException", "Resource cannot be deleted as it is currently protected against deletion", nil)
+	}
+	delete(svc.tables, *input.TableName)
+	return &dynamodb.DeleteTableOutput{TableDescription: table.description}, nil
+}
+
+func (svc *DynamoDB) UpdateContinuousBackups(input *dynamodb.UpdateContinuousBackupsInput) (*dynamodb.UpdateContinuousBackupsOutput, error) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	table, err := svc.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	table.pointInTimeRecovery = aws.BoolValue(input.PointInTimeRecoverySpecification.PointInTimeRecoveryEnabled)
+	return &dynamodb.UpdateContinuousBackupsOutput{ContinuousBackupsDescription: table.continuousBackups()}, nil
+}
+
+func (svc *DynamoDB) DescribeContinuousBackups(input *dynamodb.DescribeContinuousBackupsInput) (*dynamodb.DescribeContinuousBackupsOutput, error) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	table, err := svc.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	return &dynamodb.DescribeContinuousBackupsOutput{ContinuousBackupsDescription: table.continuousBackups()}, nil
+}
+
+func (table *fakeTable) continuousBackups() *dynamodb.ContinuousBackupsDescription {
+	status := dynamodb.PointInTimeRecoveryStatusDisabled
+	if table.pointInTimeRecovery {
+		status = dynamodb.PointInTimeRecoveryStatusEnabled
+	}
+	return &dynamodb.ContinuousBackupsDescription{
+		ContinuousBackupsStatus: aws.String(dynamodb.ContinuousBackupsStatusEnabled),
+		PointInTimeRecoveryDescription: &dynamodb.PointInTimeRecoveryDescription{
+			PointInTimeRecoveryStatus: aws.String(status),
+		},
+	}
+}
+
+// tableByArn returns the table with the given ARN.
+func (svc *DynamoDB) tableByArn(arn *string) (*fakeTable, error) {
+	for _, table := range svc.tables {
+		if aws.StringValue(table.description.TableArn) == aws.StringValue(arn) {
+			return table, nil
+		}
+	}
+	return nil, awserr.New(dynamodb.ErrCodeResourceNotFoundException, "Requested resource not found: "+aws.StringValue(arn), nil)
+}
+
+func (svc *DynamoDB) ListTagsOfResource(input *dynamodb.ListTagsOfResourceInput) (*dynamodb.ListTagsOfResourceOutput, error) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	table, err := svc.tableByArn(input.ResourceArn)
+	if err != nil {
+		return nil, err
+	}
+	return &dynamodb.ListTagsOfResourceOutput{Tags: table.tags}, nil
+}
+
+func (svc *DynamoDB) TagResource(input *dynamodb.TagResourceInput) (*dynamodb.TagResourceOutput, error) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	table, err := svc.tableByArn(input.ResourceArn)
+	if err != nil {
+		return nil, err
+	}
+	for _, tag := range input.Tags {
+		replaced := false
+		for _, existing := range table.tags {
+			if aws.StringValue(existing.Key) == aws.StringValue(tag.Key) {
+				existing.Value = tag.Value
+				replaced = true
+			}
+		}
+		if !replaced {
+			table.tags = append(table.tags, tag)
+		}
+	}
+	return &dynamodb.TagResourceOutput{}, nil
+}
+
+// ListTablesPages returns all the tables, sorted by name, in a single page.
+func (svc *DynamoDB) ListTablesPages(input *dynamodb.ListTablesInput, fn func(*dynamodb.ListTablesOutput, bool) bool) error {
+	svc.mu.Lock()
+	names := []string{}
+	for name := range svc.tables {
+		names = append(names, name)
+	}
+	svc.mu.Unlock()
+	sort.Strings(names)
+	fn(&dynamodb.ListTablesOutput{TableNames: aws.StringSlice(names)}, true)
+	return nil
+}
+
+func (svc *DynamoDB) WaitUntilTableExists(input *dynamodb.DescribeTableInput) error {
+	_, err := svc.DescribeTable(input)
+	return err
+}
+
+func (svc *DynamoDB) WaitUntilTableNotExists(input *dynamodb.DescribeTableInput) error {
+	return nil
+}
+
+func (svc *DynamoDB) BatchWriteItem(input *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	unprocessed := map[string][]*dynamodb.WriteRequest{}
+	for tableName, requests := range input.RequestItems {
+		if len(requests) > 25 {
+			return nil, awserr.New("ValidationException", "Too many items requested for the BatchWriteItem call", nil)
+		}
+		table, err := svc.table(aws.String(tableName))
+		if err != nil {
+			return nil, err
+		}
+		if svc.UnprocessedWrites > 0 {
+			unprocessed[tableName] = requests
+			continue
+		}
+		for _, request := range requests {
+			switch {
+			case request.PutRequest != nil:
+				id, err := table.itemID(request.PutRequest.Item)
+				if err != nil {
+					return nil, err
+				}
+				table.items[id] = request.PutRequest.Item
+			case request.DeleteRequest != nil:
+				id, err := table.itemID(request.DeleteRequest.Key)
+				if err != nil {
+					return nil, err
+				}
+				delete(table.items, id)
+			}
+		}
+	}
+	if svc.UnprocessedWrites > 0 {
+		svc.UnprocessedWrites--
+	}
+	return &dynamodb.BatchWriteItemOutput{UnprocessedItems: unprocessed}, nil
+}
+
+// TransactWriteItems supports Put actions. All conditions are checked before
+// any item is written.
+func (svc *DynamoDB) TransactWriteItems(input *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	if len(input.TransactItems) > 100 {
+		return nil, awserr.New("ValidationException", "Too many items in the TransactWriteItems call", nil)
+	}
+	if svc.ConflictingTransactions > 0 {
+		svc.ConflictingTransactions--
+		reasons := []*dynamodb.CancellationReason{}
+		for range input.TransactItems {
+			reasons = append(reasons, &dynamodb.CancellationReason{Code: aws.String("TransactionConflict")})
+		}
+		return nil, &dynamodb.TransactionCanceledException{
+			Message_:            aws.String("Transaction cancelled, please refer cancellation reasons for specific reasons"),
+			CancellationReasons: reasons,
+		}
+	}
+	tables := []*fakeTable{}
+	ids := []string{}
+	reasons := []*dynamodb.CancellationReason{}
+	canceled := false
+	for _, transactItem := range input.TransactItems {
+		put := transactItem.Put
+		if put == nil {
+			return nil, fmt.Errorf("fake: unsupported transaction action %s", transactItem)
+		}
+		table, err := svc.table(put.TableName)
+		if err != nil {
+			return nil, err
+		}
+		id, err := table.itemID(put.Item)
+		if err != nil {
+			return nil, err
+		}
+		reason := &dynamodb.CancellationReason{Code: aws.String("None")}
+		if put.ConditionExpression != nil {
+			ok, err := evaluateCondition(*put.ConditionExpression, table.items[id], put.ExpressionAttributeNames, put.ExpressionAttributeValues)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				reason = &dynamodb.CancellationReason{Code: aws.String("ConditionalCheckFailed"), Message: aws.String("The conditional request failed")}
+				canceled = true
+			}
+		}
+		tables = append(tables, table)
+		ids = append(ids, id)
+		reasons = append(reasons, reason)
+	}
+	if canceled {
+		return nil, &dynamodb.TransactionCanceledException{
+			Message_:            aws.String("Transaction cancelled, please refer cancellation reasons for specific reasons"),
+			CancellationReasons: reasons,
+		}
+	}
+	for i, transactItem := range input.TransactItems {
+		tables[i].items[ids[i]] = transactItem.Put.Item
+	}
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+func (svc *DynamoDB) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	table, err := svc.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	id, err := table.itemID(input.Key)
+	if err != nil {
+		return nil, err
+	}
+	output := &dynamodb.GetItemOutput{}
+	if item, ok := table.items[id]; ok {
+		output.Item = project(item, input.AttributesToGet, input.ProjectionExpression, input.ExpressionAttributeNames)
+	}
+	return output, nil
+}
+
+func (svc *DynamoDB) BatchGetItem(input *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	responses := map[string][]map[string]*dynamodb.AttributeValue{}
+	for tableName, keysAndAttributes := range input.RequestItems {
+		if len(keysAndAttributes.Keys) > 100 {
+			return nil, awserr.New("ValidationException", "Too many items requested for the BatchGetItem call", nil)
+		}
+		table, err := svc.table(aws.String(tableName))
+		if err != nil {
+			return nil, err
+		}
+		responses[tableName] = []map[string]*dynamodb.AttributeValue{}
+		requested := map[string]bool{}
+		for _, key := range keysAndAttributes.Keys {
+			id, err := table.itemID(key)
+			if err != nil {
+				return nil, err
+			}
+			if requested[id] {
+				return nil, awserr.New("ValidationException", "Provided list of item keys contains duplicates", nil)
+			}
+			requested[id] = true
+			if item, ok := table.items[id]; ok {
+				responses[tableName] = append(responses[tableName], project(item, keysAndAttributes.AttributesToGet, keysAndAttributes.ProjectionExpression, keysAndAttributes.ExpressionAttributeNames))
+			}
+		}
+	}
+	return &dynamodb.BatchGetItemOutput{Responses: responses, UnprocessedKeys: map[string]*dynamodb.KeysAndAttributes{}}, nil
+}
+
+func (svc *DynamoDB) DeleteItem(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	table, err := svc.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	id, err := table.itemID(input.Key)
+	if err != nil {
+		return nil, err
+	}
+	existing := table.items[id]
+	if input.ConditionExpression != nil {
+		ok, err := evaluateCondition(*input.ConditionExpression, existing, input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, conditionalCheckFailed()
+		}
+	}
+	delete(table.items, id)
+	output := &dynamodb.DeleteItemOutput{}
+	if aws.StringValue(input.ReturnValues) == dynamodb.ReturnValueAllOld {
+		output.Attributes = existing
+	}
+	return output, nil
+}
+
+func (svc *DynamoDB) Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	table, err := svc.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	items := []map[string]*dynamodb.AttributeValue{}
+	for _, id := range table.sortedIDs() {
+		items = append(items, project(table.items[id], input.AttributesToGet, input.ProjectionExpression, input.ExpressionAttributeNames))
+	}
+	return &dynamodb.ScanOutput{Items: items, Count: aws.Int64(int64(len(items)))}, nil
+}
+
+func (svc *DynamoDB) ScanPages(input *dynamodb.ScanInput, fn func(*dynamodb.ScanOutput, bool) bool) error {
+	output, err := svc.Scan(input)
+	if err != nil {
+		return err
+	}
+	fn(output, true)
+	return nil
+}
+
+func (svc *DynamoDB) QueryPages(input *dynamodb.QueryInput, fn func(*dynamodb.QueryOutput, bool) bool) error {
+	output, err := svc.Query(input)
+	if err != nil {
+		return err
+	}
+	fn(output, true)
+	return nil
+}
+
+func (svc *DynamoDB) Query(input *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	table, err := svc.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	items := []map[string]*dynamodb.AttributeValue{}
+	for _, id := range table.sortedIDs() {
+		item := table.items[id]
+		matches := true
+		for name, condition := range input.KeyConditions {
+			value, ok := item[name]
+			if !ok || aws.StringValue(condition.ComparisonOperator) != dynamodb.ComparisonOperatorEq ||
+				attributeString(value) != attributeString(condition.AttributeValueList[0]) {
+				matches = false
+			}
+		}
+		if matches && input.KeyConditionExpression != nil {
+			matches, err = evaluateCondition(*input.KeyConditionExpression, item, input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if matches {
+			items = append(items, project(item, input.AttributesToGet, input.ProjectionExpression, input.ExpressionAttributeNames))
+		}
+	}
+	if input.ScanIndexForward != nil && !*input.ScanIndexForward {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+	if input.Limit != nil && int64(len(items)) > *input.Limit {
+		items = items[:*input.Limit]
+	}
+	return &dynamodb.QueryOutput{Items: items, Count: aws.Int64(int64(len(items)))}, nil
+}
+
+// project returns the requested attributes of item.
+func project(item map[string]*dynamodb.AttributeValue, attributesToGet []*string, projection *string, names map[string]*string) map[string]*dynamodb.AttributeValue {
+	attributes := aws.StringValueSlice(attributesToGet)
+	if projection != nil {
+		for _, attribute := range strings.Split(*projection, ",") {
+			attributes = append(attributes, resolveName(strings.TrimSpace(attribute), names))
+		}
+	}
+	if len(attributes) == 0 {
+		return item
+	}
+	projected := map[string]*dynamodb.AttributeValue{}
+	for _, attribute := range attributes {
+		if value, ok := item[attribute]; ok {
+			projected[attribute] = value
+		}
+	}
+	return projected
+}
+
+func resolveName(name string, names map[string]*string) string {
+	if resolved, ok := names[name]; ok {
+		return *resolved
+	}
+	return name
+}
+
+// evaluateCondition evaluates the subset of the condition expression syntax
+// used by dynamokv: comparisons with "=" and "<>", attribute_exists,
+// attribute_not_exists and begins_with, combined with AND and OR.
+func evaluateCondition(expression string, item map[string]*dynamodb.AttributeValue, names map[string]*string, values map[string]*dynamodb.AttributeValue) (bool, error) {
+	for _, disjunct := range strings.Split(expression, " OR ") {
+		matches := true
+		for _, term := range strings.Split(disjunct, " AND ") {
+			ok, err := evaluateTerm(strings.TrimSpace(term), item, names, values)
+			if err != nil {
+				return false, err
+			}
+			matches = matches && ok
+		}
+		if matches {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func evaluateTerm(term string, item map[string]*dynamodb.AttributeValue, names map[string]*string, values map[string]*dynamodb.AttributeValue) (bool, error) {
+	if strings.HasPrefix(term, "(") && strings.HasSuffix(term, ")") {
+		term = term[1 : len(term)-1]
+	}
+	if open := strings.Index(term, "("); open > 0 && strings.HasSuffix(term, ")") {
+		function := term[:open]
+		args := strings.Split(term[open+1:len(term)-1], ",")
+		name := resolveName(strings.TrimSpace(args[0]), names)
+		value, exists := item[name]
+		switch function {
+		case "attribute_exists":
+			return exists, nil
+		case "attribute_not_exists":
+			return !exists, nil
+		case "begins_with":
+			if len(args) != 2 {
+				return false, fmt.Errorf("fake: invalid begins_with: %s", term)
+			}
+			prefix, ok := values[strings.TrimSpace(args[1])]
+			if !ok {
+				return false, fmt.Errorf("fake: missing value in %s", term)
+			}
+			return exists && strings.HasPrefix(attributeString(value), attributeString(prefix)), nil
+		}
+		return false, fmt.Errorf("fake: unsupported function %s", function)
+	}
+	for _, operator := range []string{"<>", "="} {
+		if parts := strings.SplitN(term, " "+operator+" ", 2); len(parts) == 2 {
+			name := resolveName(strings.TrimSpace(parts[0]), names)
+			expected, ok := values[strings.TrimSpace(parts[1])]
+			if !ok {
+				return false, fmt.Errorf("fake: missing value in %s", term)
+			}
+			value, exists := item[name]
+			equal := exists && attributeString(value) == attributeString(expected)
+			if operator == "<>" {
+				return !equal, nil
+			}
+			return equal, nil
+		}
+	}
+	return false, fmt.Errorf("fake: unsupported condition %s", term)
+}
@@ -0,0 +1,144 @@
+// Package fake provides in-memory implementations of the AWS clients used by
+// dynamokv so that tests run without AWS or DynamoDB Local.
+package fake
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"reflect"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+)
+
+// KMS is a fake KMS client. Ciphertexts embed the key id, the encryption
+// context and the plaintext, and only decrypt with the same context.
+// Any non-empty key id is accepted.
+type KMS struct {
+	kmsiface.KMSAPI
+
+	mu sync.Mutex
+	// Calls counts the calls made to each operation.
+	Calls map[string]int
+	// Aliases maps aliases and key ids to key ARNs. Other key ids are their
+	// own ARN.
+	Aliases map[string]string
+}
+
+func NewKMS() *KMS {
+	return &KMS{Calls: map[string]int{}, Aliases: map[string]string{}}
+}
+
+// keyArn returns the ARN of keyID, recorded in ciphertexts as KMS does.
+func (svc *KMS) keyArn(keyID string) string {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	if arn, ok := svc.Aliases[keyID]; ok {
+		return arn
+	}
+	return keyID
+}
+
+type ciphertext struct {
+	KeyID     string
+	Context   map[string]string
+	Plaintext []byte
+}
+
+func (svc *KMS) call(operation string) {
+	svc.mu.Lock()
+	svc.Calls[operation]++
+	svc.mu.Unlock()
+}
+
+func (svc *KMS) encrypt(keyID *string, plaintext []byte, encryptionContext map[string]*string) ([]byte, error) {
+	if aws.StringValue(keyID) == "" {
+		return nil, awserr.New(kms.ErrCodeNotFoundException, "key not found", nil)
+	}
+	return json.Marshal(ciphertext{
+		KeyID:     svc.keyArn(*keyID),
+		Context:   aws.StringValueMap(encryptionContext),
+		Plaintext: plaintext,
+	})
+}
+
+func (svc *KMS) decrypt(blob []byte, encryptionContext map[string]*string) (*ciphertext, error) {
+	var decoded ciphertext
+	if err := json.Unmarshal(blob, &decoded); err != nil {
+		return nil, awserr.New(kms.ErrCodeInvalidCiphertextException, "invalid ciphertext", err)
+	}
+	expected := aws.StringValueMap(encryptionContext)
+	if len(decoded.Context) != 0 || len(expected) != 0 {
+		if !reflect.DeepEqual(decoded.Context, expected) {
+			return nil, awserr.New(kms.ErrCodeInvalidCiphertextException, "encryption context mismatch", nil)
+		}
+	}
+	return &decoded, nil
+}
+
+func (svc *KMS) Encrypt(input *kms.EncryptInput) (*kms.EncryptOutput, error) {
+	svc.call("Encrypt")
+	blob, err := svc.encrypt(input.KeyId, input.Plaintext, input.EncryptionContext)
+	if err != nil {
+		return nil, err
+	}
+	return &kms.EncryptOutput{CiphertextBlob: blob, KeyId: input.KeyId}, nil
+}
+
+func (svc *KMS) Decrypt(input *kms.DecryptInput) (*kms.DecryptOutput, error) {
+	svc.call("Decrypt")
+	decoded, err := svc.decrypt(input.CiphertextBlob, input.EncryptionContext)
+	if err != nil {
+		return nil, err
+	}
+	return &kms.DecryptOutput{Plaintext: decoded.Plaintext, KeyId: aws.String(decoded.KeyID)}, nil
+}
+
+func (svc *KMS) ReEncrypt(input *kms.ReEncryptInput) (*kms.ReEncryptOutput, error) {
+	svc.call("ReEncrypt")
+	decoded, err := svc.decrypt(input.CiphertextBlob, input.SourceEncryptionContext)
+	if err != nil {
+		return nil, err
+	}
+	blob, err := svc.encrypt(input.DestinationKeyId, decoded.Plaintext, input.DestinationEncryptionContext)
+	if err != nil {
+		return nil, err
+	}
+	return &kms.ReEncryptOutput{
+		CiphertextBlob: blob,
+		KeyId:          input.DestinationKeyId,
+		SourceKeyId:    aws.String(decoded.KeyID),
+	}, nil
+}
+
+func (svc *KMS) DescribeKey(input *kms.DescribeKeyInput) (*kms.DescribeKeyOutput, error) {
+	svc.call("DescribeKey")
+	if aws.StringValue(input.KeyId) == "" {
+		return nil, awserr.New(kms.ErrCodeNotFoundException, "key not found", nil)
+	}
+	arn := svc.keyArn(*input.KeyId)
+	return &kms.DescribeKeyOutput{KeyMetadata: &kms.KeyMetadata{Arn: aws.String(arn), KeyId: aws.String(arn)}}, nil
+}
+
+func (svc *KMS) GenerateDataKey(input *kms.GenerateDataKeyInput) (*kms.GenerateDataKeyOutput, error) {
+	svc.call("GenerateDataKey")
+	size := 32
+	if aws.StringValue(input.KeySpec) == kms.DataKeySpecAes128 {
+		size = 16
+	}
+	if input.NumberOfBytes != nil {
+		size = int(*input.NumberOfBytes)
+	}
+	plaintext := make([]byte, size)
+	if _, err := rand.Read(plaintext); err != nil {
+		return nil, err
+	}
+	blob, err := svc.encrypt(input.KeyId, plaintext, input.EncryptionContext)
+	if err != nil {
+		return nil, err
+	}
+	return &kms.GenerateDataKeyOutput{CiphertextBlob: blob, Plaintext: plaintext, KeyId: input.KeyId}, nil
+}
@@ -0,0 +1,26 @@
+package fake
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+)
+
+// STS is a fake STS client returning a fixed caller identity.
+type STS struct {
+	stsiface.STSAPI
+
+	Arn string
+}
+
+func NewSTS(arn string) *STS {
+	return &STS{Arn: arn}
+}
+
+func (svc *STS) GetCallerIdentity(input *sts.GetCallerIdentityInput) (*sts.GetCallerIdentityOutput, error) {
+	return &sts.GetCallerIdentityOutput{
+		Account: aws.String("000000000000"),
+		Arn:     aws.String(svc.Arn),
+		UserId:  aws.String("AIDAFAKE"),
+	}, nil
+}
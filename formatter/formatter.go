@@ -0,0 +1,183 @@
+// Package formatter writes items in the output formats supported by the
+// fetch and get commands.
+package formatter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/diasjorge/dynamokv/models"
+	"gopkg.in/yaml.v2"
+)
+
+// Formatter writes items to w.
+type Formatter interface {
+	Format(w io.Writer, items []*models.Item) error
+}
+
+// Formats lists the supported output formats.
+var Formats = []string{"shell", "json", "yaml", "dotenv", "docker", "raw"}
+
+// New returns the Formatter for format. export prefixes variables with
+// "export" in the shell and dotenv formats.
+func New(format string, export bool) (Formatter, error) {
+	switch format {
+	case "", "shell":
+		return shellFormatter{export: export}, nil
+	case "json":
+		return jsonFormatter{}, nil
+	case "yaml":
+		return NewYAML(false), nil
+	case "dotenv":
+		return dotenvFormatter{export: export}, nil
+	case "docker":
+		return dockerFormatter{}, nil
+	case "raw":
+		return rawFormatter{}, nil
+	default:
+		return nil, fmt.Errorf("Unknown format %s. Supported formats: %s", format, strings.Join(Formats, ", "))
+	}
+}
+
+// shellFormatter writes KEY='value' lines that can be evaluated by a shell.
+type shellFormatter struct {
+	export bool
+}
+
+func (f shellFormatter) Format(w io.Writer, items []*models.Item) error {
+	format := "%s='%s'\n"
+	if f.export {
+		format = "export " + format
+	}
+	for _, item := range items {
+		if _, err := fmt.Fprintf(w, format, item.Key, strings.Replace(item.Value, "'", "'\\''", -1)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dotenvFormatter writes KEY="value" lines with escaped double quoted values.
+type dotenvFormatter struct {
+	export bool
+}
+
+var dotenvReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "$", `\$`)
+
+func (f dotenvFormatter) Format(w io.Writer, items []*models.Item) error {
+	format := "%s=\"%s\"\n"
+	if f.export {
+		format = "export " + format
+	}
+	for _, item := range items {
+		if _, err := fmt.Fprintf(w, format, item.Key, dotenvReplacer.Replace(item.Value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dockerFormatter writes KEY=value lines for docker --env-file, which does
+// not support quoting or multi-line values.
+type dockerFormatter struct{}
+
+func (dockerFormatter) Format(w io.Writer, items []*models.Item) error {
+	for _, item := range items {
+		if strings.ContainsAny(item.Value, "\r\n") {
+			return fmt.Errorf("Value of %s contains a newline, which is not supported by the docker format", item.Key)
+		}
+		if _, err := fmt.Fprintf(w, "%s=%s\n", item.Key, item.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rawFormatter writes only the values, one per line.
+type rawFormatter struct{}
+
+func (rawFormatter) Format(w io.Writer, items []*models.Item) error {
+	for _, item := range items {
+		if _, err := fmt.Fprintln(w, item.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type jsonItem struct {
+	Value                string            `json:"value"`
+	Serialization        string            `json:"serialization"`
+	SerializationOptions map[string]string `json:"serialization_options,omitempty"`
+	Version              int64             `json:"version,omitempty"`
+	UpdatedAt            string            `json:"updated_at,omitempty"`
+	UpdatedBy            string            `json:"updated_by,omitempty"`
+	Source               string            `json:"source,omitempty"`
+}
+
+// jsonFormatter writes an object keyed by item Key.
+type jsonFormatter struct{}
+
+func (jsonFormatter) Format(w io.Writer, items []*models.Item) error {
+	output := map[string]jsonItem{}
+	for _, item := range items {
+		entry := jsonItem{
+			Value:                item.Value,
+			Serialization:        item.Serialization,
+			SerializationOptions: item.SerializationOptions,
+			Version:              item.Version,
+			UpdatedBy:            item.UpdatedBy,
+			Source:               item.Source,
+		}
+		if !item.UpdatedAt.IsZero() {
+			entry.UpdatedAt = item.UpdatedAt.Format(time.RFC3339)
+		}
+		output[item.Key] = entry
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(output)
+}
+
+// NewYAML returns a Formatter that writes items in the format accepted by the
+// store command. Items that are not plain declare their serialization. When
+// serialized is true the values are written as stored and marked so that
+// store does not serialize them again, otherwise store serializes them.
+func NewYAML(serialized bool) Formatter {
+	return yamlFormatter{serialized: serialized}
+}
+
+type yamlFormatter struct {
+	serialized bool
+}
+
+func (f yamlFormatter) Format(w io.Writer, items []*models.Item) error {
+	output := yaml.MapSlice{}
+	for _, item := range items {
+		if item.Serialization == "" || item.Serialization == "plain" {
+			output = append(output, yaml.MapItem{Key: item.Key, Value: item.Value})
+			continue
+		}
+		serialization := yaml.MapSlice{{Key: "type", Value: item.Serialization}}
+		if len(item.SerializationOptions) > 0 {
+			serialization = append(serialization, yaml.MapItem{Key: "options", Value: item.SerializationOptions})
+		}
+		value := yaml.MapSlice{
+			{Key: "serialization", Value: serialization},
+			{Key: "value", Value: item.Value},
+		}
+		if f.serialized {
+			value = append(value, yaml.MapItem{Key: "serialized", Value: true})
+		}
+		output = append(output, yaml.MapItem{Key: item.Key, Value: value})
+	}
+	data, err := yaml.Marshal(output)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
@@ -0,0 +1,85 @@
+package formatter
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/diasjorge/dynamokv/models"
+	"github.com/stretchr/testify/assert"
+)
+
+var testItems = []*models.Item{
+	{Key: "KEY", Value: "it's a \"value\"", Serialization: "plain"},
+	{Key: "SECRET", Value: "secret", Serialization: "kms", SerializationOptions: map[string]string{"key": "alias/key"}},
+}
+
+func format(t *testing.T, name string, export bool, items []*models.Item) string {
+	formatter, err := New(name, export)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := formatter.Format(&buf, items); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestShell(t *testing.T) {
+	assert.Equal(t, "KEY='it'\\''s a \"value\"'\nSECRET='secret'\n", format(t, "shell", false, testItems))
+	assert.Equal(t, "export KEY='it'\\''s a \"value\"'\nexport SECRET='secret'\n", format(t, "shell", true, testItems))
+}
+
+func TestDotenv(t *testing.T) {
+	items := []*models.Item{{Key: "KEY", Value: "line \"one\"\n$HOME"}}
+
+	assert.Equal(t, "KEY=\"line \\\"one\\\"\\n\\$HOME\"\n", format(t, "dotenv", false, items))
+}
+
+func TestDocker(t *testing.T) {
+	assert.Equal(t, "KEY=it's a \"value\"\nSECRET=secret\n", format(t, "docker", false, testItems))
+
+	formatter, _ := New("docker", false)
+	err := formatter.Format(&bytes.Buffer{}, []*models.Item{{Key: "KEY", Value: "multi\nline"}})
+	assert.EqualError(t, err, "Value of KEY contains a newline, which is not supported by the docker format")
+}
+
+func TestRaw(t *testing.T) {
+	assert.Equal(t, "it's a \"value\"\nsecret\n", format(t, "raw", false, testItems))
+}
+
+func TestJSON(t *testing.T) {
+	expected := `{
+  "KEY": {
+    "value": "it's a \"value\"",
+    "serialization": "plain"
+  },
+  "SECRET": {
+    "value": "secret",
+    "serialization": "kms",
+    "serialization_options": {
+      "key": "alias/key"
+    }
+  }
+}
+`
+	assert.Equal(t, expected, format(t, "json", false, testItems))
+}
+
+func TestYAML(t *testing.T) {
+	expected := `KEY: it's a "value"
+SECRET:
+  serialization:
+    type: kms
+    options:
+      key: alias/key
+  value: secret
+`
+	assert.Equal(t, expected, format(t, "yaml", false, testItems))
+}
+
+func TestUnknownFormat(t *testing.T) {
+	_, err := New("xml", false)
+
+	assert.EqualError(t, err, "Unknown format xml. Supported formats: shell, json, yaml, dotenv, docker, raw")
+}
@@ -2,24 +2,55 @@ package models
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 )
 
 type Item struct {
-	Key           string
-	Value         string
-	Serialization string
+	Key                  string
+	Value                string
+	Serialization        string
+	SerializationOptions map[string]string
+	// Version is incremented every time the item is written. Items written
+	// by older versions of dynamokv have Version 0.
+	Version int64
+	Metadata
 }
 
 type ParsedItem struct {
-	Key   string
-	Value *ParsedItemValue
+	Key     string
+	Value   *ParsedItemValue
+	Version int64
+	Metadata
+}
+
+// Metadata records when, by whom and from where an item was last written.
+// It is empty for items written by older versions of dynamokv.
+type Metadata struct {
+	UpdatedAt time.Time
+	// UpdatedBy is the ARN of the AWS identity that wrote the item.
+	UpdatedBy string
+	// Source is the command, and file if any, that wrote the item.
+	Source string
+}
+
+// HistoryEntry is a version of an item as it was stored, so encrypted values
+// stay encrypted.
+type HistoryEntry struct {
+	Item      *ParsedItem
+	Timestamp time.Time
+	Author    string
 }
 
 type ParsedItemValue struct {
 	Value         string
 	Serialization *Serialization
+	// Serialized is true when Value is already serialized, e.g. when
+	// restoring a dump, and must be stored as is.
+	Serialized bool
 }
 
 type Serialization struct {
@@ -45,6 +76,28 @@ func NewSerialization() *Serialization {
 	return &Serialization{Type: "plain"}
 }
 
+// SerializationSeparator separates the steps of a chained serialization type,
+// e.g. "gzip,kms".
+const SerializationSeparator = ","
+
+// Steps returns the serialization types applied, in order, when serializing.
+func (serialization *Serialization) Steps() []string {
+	return strings.Split(serialization.Type, SerializationSeparator)
+}
+
+// NamespaceSeparator separates the namespace from the key name in the Key
+// attribute, e.g. "prod/api::DB_PASSWORD".
+const NamespaceSeparator = "::"
+
+// NamespacedKey returns the Key attribute of key in namespace. Keys in the
+// default namespace, "", are stored as is.
+func NamespacedKey(namespace, key string) string {
+	if namespace == "" {
+		return key
+	}
+	return namespace + NamespaceSeparator + key
+}
+
 func NewParsedItemFromDynamoDB(dynamodbItem map[string]*dynamodb.AttributeValue) (*ParsedItem, error) {
 	item := NewParsedItem()
 	key, ok := dynamodbItem["Key"]
@@ -61,5 +114,51 @@ func NewParsedItemFromDynamoDB(dynamodbItem map[string]*dynamodb.AttributeValue)
 	if ok {
 		item.Value.Serialization.Type = *serialization.S
 	}
+	if version, ok := dynamodbItem["Version"]; ok && version.N != nil {
+		parsedVersion, err := strconv.ParseInt(*version.N, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid Version attribute for item: %v", dynamodbItem)
+		}
+		item.Version = parsedVersion
+	}
+	if options, ok := dynamodbItem["SerializationOptions"]; ok && len(options.M) > 0 {
+		item.Value.Serialization.Options = map[string]string{}
+		for name, value := range options.M {
+			if value.S != nil {
+				item.Value.Serialization.Options[name] = *value.S
+			}
+		}
+	}
+	if updatedAt, ok := dynamodbItem["UpdatedAt"]; ok && updatedAt.S != nil {
+		parsedUpdatedAt, err := time.Parse(time.RFC3339, *updatedAt.S)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid UpdatedAt attribute for item: %v", dynamodbItem)
+		}
+		item.UpdatedAt = parsedUpdatedAt
+	}
+	if updatedBy, ok := dynamodbItem["UpdatedBy"]; ok && updatedBy.S != nil {
+		item.UpdatedBy = *updatedBy.S
+	}
+	if source, ok := dynamodbItem["Source"]; ok && source.S != nil {
+		item.Source = *source.S
+	}
 	return item, nil
 }
+
+func NewHistoryEntryFromDynamoDB(dynamodbItem map[string]*dynamodb.AttributeValue) (*HistoryEntry, error) {
+	item, err := NewParsedItemFromDynamoDB(dynamodbItem)
+	if err != nil {
+		return nil, err
+	}
+	entry := &HistoryEntry{Item: item}
+	if timestamp, ok := dynamodbItem["Timestamp"]; ok && timestamp.S != nil {
+		entry.Timestamp, err = time.Parse(time.RFC3339, *timestamp.S)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid Timestamp attribute for item: %v", dynamodbItem)
+		}
+	}
+	if author, ok := dynamodbItem["Author"]; ok && author.S != nil {
+		entry.Author = *author.S
+	}
+	return entry, nil
+}
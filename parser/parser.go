@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"fmt"
 	"io/ioutil"
 	"strings"
 
@@ -12,22 +13,59 @@ import (
 type rawValue struct {
 	RawSerialization interface{} `mapstructure:"serialization"`
 	RawValue         interface{} `mapstructure:"value"`
+	Serialized       bool        `mapstructure:"serialized"`
 }
 
 func (rawValue *rawValue) parseSerialization() (*models.Serialization, error) {
+	switch rawValue.RawSerialization.(type) {
+	case []interface{}:
+		return parseSerializationChain(rawValue.RawSerialization.([]interface{}))
+	default:
+		return parseSerializationStep(rawValue.RawSerialization)
+	}
+}
+
+func parseSerializationStep(rawSerialization interface{}) (*models.Serialization, error) {
 	serialization := models.NewSerialization()
 
-	switch rawValue.RawSerialization.(type) {
+	switch rawSerialization.(type) {
 	case string:
-		serialization.Type = rawValue.RawSerialization.(string)
+		serialization.Type = rawSerialization.(string)
 	case interface{}:
-		if err := mapstructure.Decode(rawValue.RawSerialization, &serialization); err != nil {
+		if err := mapstructure.Decode(rawSerialization, &serialization); err != nil {
 			return nil, err
 		}
 	}
 	return serialization, nil
 }
 
+// parseSerializationChain combines a list of serialization steps into a
+// single chained serialization. Options from every step are merged.
+func parseSerializationChain(rawSteps []interface{}) (*models.Serialization, error) {
+	serialization := models.NewSerialization()
+	steps := []string{}
+	for _, rawStep := range rawSteps {
+		step, err := parseSerializationStep(rawStep)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, step.Type)
+		for name, value := range step.Options {
+			if serialization.Options == nil {
+				serialization.Options = map[string]string{}
+			}
+			if existing, ok := serialization.Options[name]; ok && existing != value {
+				return nil, fmt.Errorf("conflicting values for serialization option %s", name)
+			}
+			serialization.Options[name] = value
+		}
+	}
+	if len(steps) > 0 {
+		serialization.Type = strings.Join(steps, models.SerializationSeparator)
+	}
+	return serialization, nil
+}
+
 func (rawValue *rawValue) parseValue() (string, error) {
 	var value string
 
@@ -57,7 +95,7 @@ func (rawValue *rawValue) Parse() (*models.ParsedItemValue, error) {
 	if err != nil {
 		return nil, err
 	}
-	itemValue := &models.ParsedItemValue{Serialization: serialization, Value: value}
+	itemValue := &models.ParsedItemValue{Serialization: serialization, Value: value, Serialized: rawValue.Serialized}
 	return itemValue, nil
 }
 
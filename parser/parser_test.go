@@ -0,0 +1,111 @@
+package parser
+
+import (
+	"io/ioutil"
+	"os"
+	"sort"
+	"testing"
+
+	"github.com/diasjorge/dynamokv/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func parseConfig(t *testing.T, config string) map[string]*models.ParsedItemValue {
+	file, err := ioutil.TempFile("", "parser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString(config)
+	file.Close()
+
+	items, err := Parse(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := map[string]*models.ParsedItemValue{}
+	for _, item := range items {
+		values[item.Key] = item.Value
+	}
+	return values
+}
+
+func TestParse(t *testing.T) {
+	valueFile, err := ioutil.TempFile("", "value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(valueFile.Name())
+	valueFile.WriteString("FILE CONTENT\n")
+	valueFile.Close()
+
+	values := parseConfig(t, `
+SIMPLE_KEY: VALUE
+SERIALIZED_KEY:
+  serialization: base64
+  value: VALUE
+ENCRYPTED_KEY:
+  serialization:
+    type: kms
+    options:
+      key: alias/key
+  value: SECRET
+READ_FILE_KEY:
+  value:
+    file: `+valueFile.Name()+`
+`)
+
+	keys := []string{}
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	assert.Equal(t, []string{"ENCRYPTED_KEY", "READ_FILE_KEY", "SERIALIZED_KEY", "SIMPLE_KEY"}, keys)
+
+	assert.Equal(t, &models.ParsedItemValue{Value: "VALUE", Serialization: &models.Serialization{Type: "plain"}}, values["SIMPLE_KEY"])
+	assert.Equal(t, &models.ParsedItemValue{Value: "VALUE", Serialization: &models.Serialization{Type: "base64"}}, values["SERIALIZED_KEY"])
+	assert.Equal(t, &models.ParsedItemValue{
+		Value:         "SECRET",
+		Serialization: &models.Serialization{Type: "kms", Options: map[string]string{"key": "alias/key"}},
+	}, values["ENCRYPTED_KEY"])
+	assert.Equal(t, "FILE CONTENT", values["READ_FILE_KEY"].Value)
+	assert.Equal(t, "plain", values["READ_FILE_KEY"].Serialization.Type)
+}
+
+func TestParseSerializationChain(t *testing.T) {
+	values := parseConfig(t, `
+CHAINED_KEY:
+  serialization:
+    - gzip
+    - type: kms
+      options:
+        key: alias/key
+  value: VALUE
+`)
+
+	assert.Equal(t, &models.Serialization{Type: "gzip,kms", Options: map[string]string{"key": "alias/key"}}, values["CHAINED_KEY"].Serialization)
+	assert.Equal(t, []string{"gzip", "kms"}, values["CHAINED_KEY"].Serialization.Steps())
+}
+
+func TestParseSerializationChainConflictingOptions(t *testing.T) {
+	file, err := ioutil.TempFile("", "parser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString(`
+CHAINED_KEY:
+  serialization:
+    - type: kms
+      options:
+        key: alias/one
+    - type: envelope
+      options:
+        key: alias/two
+  value: VALUE
+`)
+	file.Close()
+
+	_, err = Parse(file.Name())
+	assert.EqualError(t, err, "conflicting values for serialization option key")
+}
@@ -0,0 +1,62 @@
+package serializer
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+const (
+	// bindContextOption controls whether the table and key names are bound
+	// into the encryption context. It is recorded on every encrypted item.
+	bindContextOption = "bind_context"
+	// contextOptionPrefix marks options that are added to the encryption
+	// context, e.g. "context.env: prod".
+	contextOptionPrefix = "context."
+
+	tableContextKey = "dynamokv:table"
+	keyContextKey   = "dynamokv:key"
+)
+
+// encryptionOptions are the options taken by the kms and envelope
+// serializers.
+var encryptionOptions = []string{"key", bindContextOption, contextOptionPrefix}
+
+// bindContext records in options that the item is bound to its table and key,
+// unless binding was explicitly disabled.
+func bindContext(options map[string]string) {
+	if options[bindContextOption] == "" {
+		options[bindContextOption] = "true"
+	}
+}
+
+// encryptionContext builds the KMS encryption context for an item from its
+// options and, when bound, the table and key names in ctx.
+func encryptionContext(ctx *Context, options map[string]string) map[string]*string {
+	encryptionContext := map[string]*string{}
+	for name, value := range options {
+		if strings.HasPrefix(name, contextOptionPrefix) {
+			encryptionContext[strings.TrimPrefix(name, contextOptionPrefix)] = aws.String(value)
+		}
+	}
+	if options[bindContextOption] == "true" {
+		encryptionContext[tableContextKey] = aws.String(ctx.TableName)
+		encryptionContext[keyContextKey] = aws.String(ctx.Key)
+	}
+	if len(encryptionContext) == 0 {
+		return nil
+	}
+	return encryptionContext
+}
+
+// canonicalContext returns a stable string representation of an encryption
+// context, suitable for cache keys and additional authenticated data.
+func canonicalContext(encryptionContext map[string]*string) string {
+	pairs := []string{}
+	for name, value := range encryptionContext {
+		pairs = append(pairs, name+"="+aws.StringValue(value))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "\n")
+}
@@ -0,0 +1,203 @@
+package serializer
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+)
+
+func init() {
+	Register("envelope", envelopeSerializer{})
+}
+
+const envelopeVersion = 1
+
+// envelopeSerializer encrypts values locally with AES-256-GCM using a data
+// key generated by the KMS key given in the "key" option. The wrapped data
+// key is stored in front of the ciphertext:
+//
+//	version (1 byte) | key length (2 bytes) | wrapped key | nonce | ciphertext
+//
+// The result is base64 encoded. The table name and "context.*" options are
+// used as KMS encryption context, while the key name is bound through the
+// GCM additional data so one data key can be shared by all items.
+type envelopeSerializer struct{}
+
+func (envelopeSerializer) Options() []string {
+	return encryptionOptions
+}
+
+func (envelopeSerializer) Serialize(ctx *Context, value []byte, options map[string]string) ([]byte, error) {
+	keyID := options["key"]
+	if keyID == "" {
+		return nil, errors.New("envelope serialization requires the key option")
+	}
+	bindContext(options)
+	kmsContext, additionalData := envelopeContext(ctx, options)
+	dataKey, err := ctx.dataKeys.generate(ctx.KMS, keyID, kmsContext)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(dataKey.plaintext)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	wrappedLen := len(dataKey.ciphertext)
+	envelope := []byte{envelopeVersion, byte(wrappedLen >> 8), byte(wrappedLen)}
+	envelope = append(envelope, dataKey.ciphertext...)
+	envelope = append(envelope, nonce...)
+	envelope = aead.Seal(envelope, nonce, value, additionalData)
+
+	return []byte(encodeBase64(envelope)), nil
+}
+
+func (envelopeSerializer) Deserialize(ctx *Context, value []byte, options map[string]string) ([]byte, error) {
+	envelope, err := decodeBase64(string(value))
+	if err != nil {
+		return nil, err
+	}
+	if len(envelope) < 3 || envelope[0] != envelopeVersion {
+		return nil, errors.New("invalid envelope: unknown version")
+	}
+	wrappedLen := int(envelope[1])<<8 | int(envelope[2])
+	envelope = envelope[3:]
+	if len(envelope) < wrappedLen {
+		return nil, errors.New("invalid envelope: truncated data key")
+	}
+	wrapped, envelope := envelope[:wrappedLen], envelope[wrappedLen:]
+
+	kmsContext, additionalData := envelopeContext(ctx, options)
+	plaintextKey, err := ctx.dataKeys.decrypt(ctx.KMS, wrapped, kmsContext)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(plaintextKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(envelope) < aead.NonceSize() {
+		return nil, errors.New("invalid envelope: truncated nonce")
+	}
+	nonce, ciphertext := envelope[:aead.NonceSize()], envelope[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("invalid envelope: %v", err)
+	}
+	return plaintext, nil
+}
+
+// envelopeContext returns the KMS encryption context for the data key and the
+// additional authenticated data for the value.
+func envelopeContext(ctx *Context, options map[string]string) (map[string]*string, []byte) {
+	itemContext := encryptionContext(ctx, options)
+	var kmsContext map[string]*string
+	for name, value := range itemContext {
+		if name == keyContextKey {
+			continue
+		}
+		if kmsContext == nil {
+			kmsContext = map[string]*string{}
+		}
+		kmsContext[name] = value
+	}
+	return kmsContext, []byte(canonicalContext(itemContext))
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+type dataKey struct {
+	plaintext  []byte
+	ciphertext []byte
+}
+
+// dataKeyCache keeps data keys for the lifetime of a Context, so a command
+// generates one data key per KMS key and unwraps each stored data key once.
+// A nil cache performs every KMS call.
+type dataKeyCache struct {
+	mu        sync.Mutex
+	generated map[string]*dataKey
+	decrypted map[string][]byte
+}
+
+func newDataKeyCache() *dataKeyCache {
+	return &dataKeyCache{
+		generated: map[string]*dataKey{},
+		decrypted: map[string][]byte{},
+	}
+}
+
+func (cache *dataKeyCache) generate(svc kmsiface.KMSAPI, keyID string, encryptionContext map[string]*string) (*dataKey, error) {
+	cacheKey := keyID + "\n" + canonicalContext(encryptionContext)
+	if cache != nil {
+		cache.mu.Lock()
+		key, ok := cache.generated[cacheKey]
+		cache.mu.Unlock()
+		if ok {
+			return key, nil
+		}
+	}
+
+	resp, err := svc.GenerateDataKey(&kms.GenerateDataKeyInput{
+		KeyId:             aws.String(keyID),
+		KeySpec:           aws.String(kms.DataKeySpecAes256),
+		EncryptionContext: encryptionContext,
+	})
+	if err != nil {
+		return nil, err
+	}
+	key := &dataKey{plaintext: resp.Plaintext, ciphertext: resp.CiphertextBlob}
+
+	if cache != nil {
+		cache.mu.Lock()
+		cache.generated[cacheKey] = key
+		cache.decrypted[string(key.ciphertext)+"\n"+canonicalContext(encryptionContext)] = key.plaintext
+		cache.mu.Unlock()
+	}
+	return key, nil
+}
+
+func (cache *dataKeyCache) decrypt(svc kmsiface.KMSAPI, wrapped []byte, encryptionContext map[string]*string) ([]byte, error) {
+	cacheKey := string(wrapped) + "\n" + canonicalContext(encryptionContext)
+	if cache != nil {
+		cache.mu.Lock()
+		plaintext, ok := cache.decrypted[cacheKey]
+		cache.mu.Unlock()
+		if ok {
+			return plaintext, nil
+		}
+	}
+
+	resp, err := svc.Decrypt(&kms.DecryptInput{
+		CiphertextBlob:    wrapped,
+		EncryptionContext: encryptionContext,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if cache != nil {
+		cache.mu.Lock()
+		cache.decrypted[cacheKey] = resp.Plaintext
+		cache.mu.Unlock()
+	}
+	return resp.Plaintext, nil
+}
@@ -0,0 +1,36 @@
+package serializer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+)
+
+func init() {
+	Register("gzip", gzipSerializer{})
+}
+
+// gzipSerializer compresses values. Its output is binary so it has to be
+// followed by a step that produces text, such as base64 or kms.
+type gzipSerializer struct{}
+
+func (gzipSerializer) Serialize(ctx *Context, value []byte, options map[string]string) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(value); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipSerializer) Deserialize(ctx *Context, value []byte, options map[string]string) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(value))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return ioutil.ReadAll(reader)
+}
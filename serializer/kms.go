@@ -0,0 +1,49 @@
+package serializer
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kms"
+)
+
+func init() {
+	Register("kms", kmsSerializer{})
+}
+
+// kmsSerializer encrypts values with the KMS key given in the "key" option
+// and stores the ciphertext base64 encoded. The table and key names, plus any
+// "context.*" options, are used as encryption context.
+type kmsSerializer struct{}
+
+func (kmsSerializer) Options() []string {
+	return encryptionOptions
+}
+
+func (kmsSerializer) Serialize(ctx *Context, value []byte, options map[string]string) ([]byte, error) {
+	bindContext(options)
+	params := &kms.EncryptInput{
+		KeyId:             aws.String(options["key"]),
+		Plaintext:         value,
+		EncryptionContext: encryptionContext(ctx, options),
+	}
+	resp, err := ctx.KMS.Encrypt(params)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(encodeBase64(resp.CiphertextBlob)), nil
+}
+
+func (kmsSerializer) Deserialize(ctx *Context, value []byte, options map[string]string) ([]byte, error) {
+	decoded, err := decodeBase64(string(value))
+	if err != nil {
+		return nil, err
+	}
+	params := &kms.DecryptInput{
+		CiphertextBlob:    decoded,
+		EncryptionContext: encryptionContext(ctx, options),
+	}
+	resp, err := ctx.KMS.Decrypt(params)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Plaintext, nil
+}
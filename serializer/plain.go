@@ -0,0 +1,28 @@
+package serializer
+
+func init() {
+	Register("plain", plainSerializer{})
+	Register("base64", base64Serializer{})
+}
+
+// plainSerializer stores values unchanged.
+type plainSerializer struct{}
+
+func (plainSerializer) Serialize(ctx *Context, value []byte, options map[string]string) ([]byte, error) {
+	return value, nil
+}
+
+func (plainSerializer) Deserialize(ctx *Context, value []byte, options map[string]string) ([]byte, error) {
+	return value, nil
+}
+
+// base64Serializer stores values encoded with standard base64.
+type base64Serializer struct{}
+
+func (base64Serializer) Serialize(ctx *Context, value []byte, options map[string]string) ([]byte, error) {
+	return []byte(encodeBase64(value)), nil
+}
+
+func (base64Serializer) Deserialize(ctx *Context, value []byte, options map[string]string) ([]byte, error) {
+	return decodeBase64(string(value))
+}
@@ -0,0 +1,111 @@
+package serializer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+	"github.com/diasjorge/dynamokv/models"
+)
+
+// Serializer transforms a value before it is stored and restores it after it
+// is read. Options are the serialization options configured for the item.
+type Serializer interface {
+	Serialize(ctx *Context, value []byte, options map[string]string) ([]byte, error)
+	Deserialize(ctx *Context, value []byte, options map[string]string) ([]byte, error)
+}
+
+// OptionsSerializer is implemented by serializers that take options. Options
+// returns the names of the options read, names ending in "." are prefixes.
+//
+// The steps of a chained serialization share the options of the item, so
+// each option of a chain must be taken by exactly one step. Steps that do not
+// implement OptionsSerializer take no options in a chain.
+type OptionsSerializer interface {
+	Serializer
+	Options() []string
+}
+
+// takesOption reports whether serializer takes the option name.
+func takesOption(serializer Serializer, name string) bool {
+	optionsSerializer, ok := serializer.(OptionsSerializer)
+	if !ok {
+		return false
+	}
+	for _, option := range optionsSerializer.Options() {
+		if option == name || strings.HasSuffix(option, ".") && strings.HasPrefix(name, option) {
+			return true
+		}
+	}
+	return false
+}
+
+// Context holds the clients available to serializers while processing items.
+// A Context should be shared by all the items handled in a command so that
+// KMS data keys are reused.
+type Context struct {
+	KMS       kmsiface.KMSAPI
+	TableName string
+	// Key is the name of the item being processed, including its namespace.
+	// It is set for each item.
+	Key string
+	// Namespace is the namespace of the items processed.
+	Namespace string
+
+	dataKeys *dataKeyCache
+}
+
+func NewContext(svc kmsiface.KMSAPI, tableName string) *Context {
+	return &Context{KMS: svc, TableName: tableName, dataKeys: newDataKeyCache()}
+}
+
+// forKey returns a copy of ctx for processing the item with the given key.
+func (ctx *Context) forKey(key string) *Context {
+	itemCtx := *ctx
+	itemCtx.Key = models.NamespacedKey(ctx.Namespace, key)
+	return &itemCtx
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Serializer{}
+)
+
+// Register makes a Serializer available under the given serialization type.
+// It panics if the type is already registered or serializer is nil.
+func Register(name string, serializer Serializer) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if serializer == nil {
+		panic("serializer: Register serializer is nil")
+	}
+	if _, dup := registry[name]; dup {
+		panic("serializer: Register called twice for type " + name)
+	}
+	registry[name] = serializer
+}
+
+// Lookup returns the Serializer registered for the given serialization type.
+func Lookup(name string) (Serializer, error) {
+	registryMu.RLock()
+	serializer, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("Unknown serialization type %s. Registered types: %s", name, strings.Join(Types(), ", "))
+	}
+	return serializer, nil
+}
+
+// Types returns a sorted list of the registered serialization types.
+func Types() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	types := []string{}
+	for name := range registry {
+		types = append(types, name)
+	}
+	sort.Strings(types)
+	return types
+}
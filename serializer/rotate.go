@@ -0,0 +1,132 @@
+package serializer
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/diasjorge/dynamokv/models"
+)
+
+// ReEncryptItem encrypts an item again under the KMS key toKey. Items with a
+// single kms step are moved with KMS ReEncrypt so the plaintext never leaves
+// KMS; other serializations are deserialized and serialized again.
+func ReEncryptItem(ctx *Context, parsedItem *models.ParsedItem, toKey string) (*models.Item, error) {
+	serialization := parsedItem.Value.Serialization
+	options := map[string]string{}
+	for name, value := range serialization.Options {
+		options[name] = value
+	}
+	options["key"] = toKey
+
+	if serialization.Type != "kms" {
+		value, err := deserialize(ctx.forKey(parsedItem.Key), parsedItem)
+		if err != nil {
+			return nil, err
+		}
+		reEncrypted := models.NewParsedItem()
+		reEncrypted.Key = parsedItem.Key
+		reEncrypted.Value.Value = value
+		reEncrypted.Value.Serialization = &models.Serialization{Type: serialization.Type, Options: options}
+		return SerializeItem(ctx, reEncrypted)
+	}
+
+	itemCtx := ctx.forKey(parsedItem.Key)
+	decoded, err := decodeBase64(parsedItem.Value.Value)
+	if err != nil {
+		return nil, err
+	}
+	sourceContext := encryptionContext(itemCtx, serialization.Options)
+	bindContext(options)
+	resp, err := ctx.KMS.ReEncrypt(&kms.ReEncryptInput{
+		CiphertextBlob:               decoded,
+		DestinationKeyId:             aws.String(toKey),
+		SourceEncryptionContext:      sourceContext,
+		DestinationEncryptionContext: encryptionContext(itemCtx, options),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &models.Item{
+		Key:                  parsedItem.Key,
+		Value:                encodeBase64(resp.CiphertextBlob),
+		Serialization:        serialization.Type,
+		SerializationOptions: options,
+	}, nil
+}
+
+// KeyMatcher finds the items encrypted with a KMS key. Key ids, ARNs and
+// aliases are resolved to the key ARN with DescribeKey, so an item stored with
+// an alias matches the ARN of its key.
+type KeyMatcher struct {
+	ctx      *Context
+	arn      string
+	resolved map[string]string
+}
+
+// NewKeyMatcher returns a KeyMatcher for keyID.
+func NewKeyMatcher(ctx *Context, keyID string) (*KeyMatcher, error) {
+	matcher := &KeyMatcher{ctx: ctx, resolved: map[string]string{}}
+	arn, err := matcher.resolve(keyID)
+	if err != nil {
+		return nil, err
+	}
+	matcher.arn = arn
+	return matcher, nil
+}
+
+func (matcher *KeyMatcher) resolve(keyID string) (string, error) {
+	if arn, ok := matcher.resolved[keyID]; ok {
+		return arn, nil
+	}
+	resp, err := matcher.ctx.KMS.DescribeKey(&kms.DescribeKeyInput{KeyId: aws.String(keyID)})
+	if err != nil {
+		return "", fmt.Errorf("describing KMS key %s: %v", keyID, err)
+	}
+	arn := aws.StringValue(resp.KeyMetadata.Arn)
+	matcher.resolved[keyID] = arn
+	return arn, nil
+}
+
+// Matches reports whether the item is encrypted with the key. Items written
+// before the key option was recorded are decrypted to find their key. It
+// returns an error if the key of an encrypted item can't be determined.
+func (matcher *KeyMatcher) Matches(parsedItem *models.ParsedItem) (bool, error) {
+	serialization := parsedItem.Value.Serialization
+	if !Encrypted(serialization) {
+		return false, nil
+	}
+	keyID := serialization.Options["key"]
+	if keyID == "" {
+		if serialization.Type != "kms" {
+			return false, fmt.Errorf("no key option for %s serialization", serialization.Type)
+		}
+		decoded, err := decodeBase64(parsedItem.Value.Value)
+		if err != nil {
+			return false, err
+		}
+		resp, err := matcher.ctx.KMS.Decrypt(&kms.DecryptInput{
+			CiphertextBlob:    decoded,
+			EncryptionContext: encryptionContext(matcher.ctx.forKey(parsedItem.Key), serialization.Options),
+		})
+		if err != nil {
+			return false, err
+		}
+		keyID = aws.StringValue(resp.KeyId)
+	}
+	arn, err := matcher.resolve(keyID)
+	if err != nil {
+		return false, err
+	}
+	return arn == matcher.arn, nil
+}
+
+// Encrypted reports whether the serialization includes an encryption step.
+func Encrypted(serialization *models.Serialization) bool {
+	for _, step := range serialization.Steps() {
+		if step == "kms" || step == "envelope" {
+			return true
+		}
+	}
+	return false
+}
@@ -3,16 +3,17 @@ package serializer
 import (
 	"encoding/base64"
 	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/kms"
 	"github.com/diasjorge/dynamokv/models"
 )
 
-func SerializeItems(svc *kms.KMS, parsedItems []*models.ParsedItem) ([]*models.Item, error) {
+func SerializeItems(ctx *Context, parsedItems []*models.ParsedItem) ([]*models.Item, error) {
 	result := []*models.Item{}
 	for _, parsedItem := range parsedItems {
-		item, err := SerializeItem(svc, parsedItem)
+		item, err := SerializeItem(ctx, parsedItem)
 		if err != nil {
 			return nil, err
 		}
@@ -21,22 +22,32 @@ func SerializeItems(svc *kms.KMS, parsedItems []*models.ParsedItem) ([]*models.I
 	return result, nil
 }
 
-func SerializeItem(svc *kms.KMS, parsedItem *models.ParsedItem) (*models.Item, error) {
-	value, err := serialize(svc, parsedItem.Value)
-	if err != nil {
-		return nil, err
+func SerializeItem(ctx *Context, parsedItem *models.ParsedItem) (*models.Item, error) {
+	// Serializers may record extra options, so work on a copy.
+	options := map[string]string{}
+	for name, value := range parsedItem.Value.Serialization.Options {
+		options[name] = value
+	}
+	value := parsedItem.Value.Value
+	if !parsedItem.Value.Serialized {
+		serialized, err := serialize(ctx.forKey(parsedItem.Key), value, parsedItem.Value.Serialization.Steps(), options)
+		if err != nil {
+			return nil, err
+		}
+		value = serialized
 	}
 	return &models.Item{
-		Key:           parsedItem.Key,
-		Value:         value,
-		Serialization: parsedItem.Value.Serialization.Type,
+		Key:                  parsedItem.Key,
+		Value:                value,
+		Serialization:        parsedItem.Value.Serialization.Type,
+		SerializationOptions: options,
 	}, nil
 }
 
-func DeserializeItems(svc *kms.KMS, parsedItems []*models.ParsedItem, deserializeItem bool) ([]*models.Item, error) {
+func DeserializeItems(ctx *Context, parsedItems []*models.ParsedItem, deserializeItem bool) ([]*models.Item, error) {
 	items := []*models.Item{}
 	for _, parsedItem := range parsedItems {
-		item, err := DeserializeItem(svc, parsedItem, deserializeItem)
+		item, err := DeserializeItem(ctx, parsedItem, deserializeItem)
 		if err != nil {
 			return nil, err
 		}
@@ -45,71 +56,94 @@ func DeserializeItems(svc *kms.KMS, parsedItems []*models.ParsedItem, deserializ
 	return items, nil
 }
 
-func DeserializeItem(svc *kms.KMS, parsedItem *models.ParsedItem, deserializeItem bool) (*models.Item, error) {
+func DeserializeItem(ctx *Context, parsedItem *models.ParsedItem, deserializeItem bool) (*models.Item, error) {
 	value := parsedItem.Value.Value
 	if deserializeItem {
-		deserializedValue, err := deserialize(svc, parsedItem)
+		deserializedValue, err := deserialize(ctx.forKey(parsedItem.Key), parsedItem)
 		if err != nil {
 			return nil, err
 		}
 		value = deserializedValue
 	}
 	return &models.Item{
-		Key:           parsedItem.Key,
-		Value:         value,
-		Serialization: parsedItem.Value.Serialization.Type,
+		Key:                  parsedItem.Key,
+		Value:                value,
+		Serialization:        parsedItem.Value.Serialization.Type,
+		SerializationOptions: parsedItem.Value.Serialization.Options,
+		Version:              parsedItem.Version,
+		Metadata:             parsedItem.Metadata,
 	}, nil
 }
 
-func serialize(svc *kms.KMS, value *models.ParsedItemValue) (string, error) {
-	switch value.Serialization.Type {
-	case "plain":
-		return value.Value, nil
-	case "base64":
-		return encodeBase64([]byte(value.Value)), nil
-	case "kms":
-		params := &kms.EncryptInput{
-			KeyId:     aws.String(value.Serialization.Options["key"]),
-			Plaintext: []byte(value.Value),
+func serialize(ctx *Context, value string, steps []string, options map[string]string) (string, error) {
+	if err := checkOptions(steps, options); err != nil {
+		return "", err
+	}
+	serialized := []byte(value)
+	for _, step := range steps {
+		serializer, err := Lookup(step)
+		if err != nil {
+			return "", err
 		}
-		resp, err := svc.Encrypt(params)
+		serialized, err = serializer.Serialize(ctx, serialized, options)
 		if err != nil {
 			return "", err
 		}
-		return encodeBase64(resp.CiphertextBlob), nil
-	default:
-		return "", fmt.Errorf("Unknown serialization type %s", value.Serialization.Type)
 	}
-
+	if !utf8.Valid(serialized) {
+		return "", fmt.Errorf("Serialization %s produces binary data. Add base64 as the last step", strings.Join(steps, models.SerializationSeparator))
+	}
+	return string(serialized), nil
 }
 
-func deserialize(svc *kms.KMS, item *models.ParsedItem) (string, error) {
-	switch item.Value.Serialization.Type {
-	case "plain":
-		return item.Value.Value, nil
-	case "base64":
-		decoded, err := decodeBase64(item.Value.Value)
-		if err != nil {
-			return "", err
+// checkOptions rejects the options of a chained serialization that are taken
+// by no step or by several steps.
+func checkOptions(steps []string, options map[string]string) error {
+	if len(steps) < 2 {
+		return nil
+	}
+	names := []string{}
+	for name := range options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	serialization := strings.Join(steps, models.SerializationSeparator)
+	for _, name := range names {
+		takenBy := []string{}
+		for _, step := range steps {
+			serializer, err := Lookup(step)
+			if err != nil {
+				return err
+			}
+			if takesOption(serializer, name) {
+				takenBy = append(takenBy, step)
+			}
+		}
+		switch len(takenBy) {
+		case 0:
+			return fmt.Errorf("Option %s is not taken by any step of serialization %s", name, serialization)
+		case 1:
+		default:
+			return fmt.Errorf("Option %s of serialization %s is ambiguous, it is taken by %s", name, serialization, strings.Join(takenBy, " and "))
 		}
-		return string(decoded), nil
-	case "kms":
-		decoded, err := decodeBase64(item.Value.Value)
+	}
+	return nil
+}
+
+func deserialize(ctx *Context, item *models.ParsedItem) (string, error) {
+	deserialized := []byte(item.Value.Value)
+	steps := item.Value.Serialization.Steps()
+	for i := len(steps) - 1; i >= 0; i-- {
+		serializer, err := Lookup(steps[i])
 		if err != nil {
 			return "", err
 		}
-		params := &kms.DecryptInput{
-			CiphertextBlob: decoded,
-		}
-		resp, err := svc.Decrypt(params)
+		deserialized, err = serializer.Deserialize(ctx, deserialized, item.Value.Serialization.Options)
 		if err != nil {
 			return "", err
 		}
-		return string(resp.Plaintext), nil
-	default:
-		return "", fmt.Errorf("Unknown serialization type %s", item.Value.Serialization.Type)
-
 	}
+	return string(deserialized), nil
 }
 
 func encodeBase64(data []byte) string {
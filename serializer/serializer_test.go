@@ -0,0 +1,278 @@
+package serializer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/diasjorge/dynamokv/fake"
+	"github.com/diasjorge/dynamokv/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func newParsedItem(key, value, serializationType string, options map[string]string) *models.ParsedItem {
+	parsedItem := models.NewParsedItem()
+	parsedItem.Key = key
+	parsedItem.Value.Value = value
+	parsedItem.Value.Serialization.Type = serializationType
+	parsedItem.Value.Serialization.Options = options
+	return parsedItem
+}
+
+// stored converts a serialized item back into the form returned by table.Read.
+func stored(item *models.Item) *models.ParsedItem {
+	return newParsedItem(item.Key, item.Value, item.Serialization, item.SerializationOptions)
+}
+
+func roundTrip(t *testing.T, ctx *Context, parsedItem *models.ParsedItem) (*models.Item, *models.Item) {
+	item, err := SerializeItem(ctx, parsedItem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deserialized, err := DeserializeItem(ctx, stored(item), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return item, deserialized
+}
+
+func TestSerializePlain(t *testing.T) {
+	item, deserialized := roundTrip(t, NewContext(nil, "TABLE"), newParsedItem("KEY", "VALUE", "plain", nil))
+
+	assert.Equal(t, "VALUE", item.Value)
+	assert.Equal(t, "VALUE", deserialized.Value)
+}
+
+func TestSerializeBase64(t *testing.T) {
+	item, deserialized := roundTrip(t, NewContext(nil, "TABLE"), newParsedItem("KEY", "VALUE", "base64", nil))
+
+	assert.Equal(t, "VkFMVUU=", item.Value)
+	assert.Equal(t, "VALUE", deserialized.Value)
+}
+
+func TestSerializeChain(t *testing.T) {
+	value := strings.Repeat("VALUE", 1000)
+	item, deserialized := roundTrip(t, NewContext(nil, "TABLE"), newParsedItem("KEY", value, "gzip,base64", nil))
+
+	assert.Equal(t, "gzip,base64", item.Serialization)
+	assert.True(t, len(item.Value) < len(value))
+	assert.Equal(t, value, deserialized.Value)
+}
+
+func TestSerializeChainOptions(t *testing.T) {
+	ctx := NewContext(nil, "TABLE")
+
+	_, err := SerializeItem(ctx, newParsedItem("KEY", "VALUE", "gzip,base64", map[string]string{"level": "9"}))
+	assert.EqualError(t, err, "Option level is not taken by any step of serialization gzip,base64")
+
+	_, err = SerializeItem(ctx, newParsedItem("KEY", "VALUE", "kms,envelope", map[string]string{"key": "alias/key"}))
+	assert.EqualError(t, err, "Option key of serialization kms,envelope is ambiguous, it is taken by kms and envelope")
+}
+
+func TestSerializeBinaryOutput(t *testing.T) {
+	_, err := SerializeItem(NewContext(nil, "TABLE"), newParsedItem("KEY", "VALUE", "gzip", nil))
+
+	assert.EqualError(t, err, "Serialization gzip produces binary data. Add base64 as the last step")
+}
+
+func TestSerializeUnknownType(t *testing.T) {
+	_, err := SerializeItem(NewContext(nil, "TABLE"), newParsedItem("KEY", "VALUE", "unknown", nil))
+
+	assert.EqualError(t, err, "Unknown serialization type unknown. Registered types: "+strings.Join(Types(), ", "))
+}
+
+type upperSerializer struct{}
+
+func (upperSerializer) Serialize(ctx *Context, value []byte, options map[string]string) ([]byte, error) {
+	return []byte(strings.ToUpper(string(value))), nil
+}
+
+func (upperSerializer) Deserialize(ctx *Context, value []byte, options map[string]string) ([]byte, error) {
+	return []byte(strings.ToLower(string(value))), nil
+}
+
+func TestRegister(t *testing.T) {
+	Register("test-upper", upperSerializer{})
+
+	item, deserialized := roundTrip(t, NewContext(nil, "TABLE"), newParsedItem("KEY", "value", "test-upper", nil))
+
+	assert.Equal(t, "VALUE", item.Value)
+	assert.Equal(t, "value", deserialized.Value)
+	assert.Contains(t, Types(), "test-upper")
+	assert.Panics(t, func() { Register("test-upper", upperSerializer{}) })
+}
+
+func TestSerializeKMS(t *testing.T) {
+	ctx := NewContext(fake.NewKMS(), "TABLE")
+	item, deserialized := roundTrip(t, ctx, newParsedItem("KEY", "SECRET", "kms", map[string]string{"key": "alias/key", "context.env": "prod"}))
+
+	assert.NotContains(t, item.Value, "SECRET")
+	assert.Equal(t, map[string]string{"key": "alias/key", "context.env": "prod", "bind_context": "true"}, item.SerializationOptions)
+	assert.Equal(t, "SECRET", deserialized.Value)
+}
+
+func TestSerializeKMSCopiedToOtherKey(t *testing.T) {
+	ctx := NewContext(fake.NewKMS(), "TABLE")
+	item, err := SerializeItem(ctx, newParsedItem("KEY", "SECRET", "kms", map[string]string{"key": "alias/key"}))
+	assert.NoError(t, err)
+
+	copied := stored(item)
+	copied.Key = "OTHER_KEY"
+	_, err = DeserializeItem(ctx, copied, true)
+	assert.Error(t, err)
+
+	_, err = DeserializeItem(NewContext(ctx.KMS, "OTHER_TABLE"), stored(item), true)
+	assert.Error(t, err)
+}
+
+func TestSerializeKMSWithoutBinding(t *testing.T) {
+	ctx := NewContext(fake.NewKMS(), "TABLE")
+	item, err := SerializeItem(ctx, newParsedItem("KEY", "SECRET", "kms", map[string]string{"key": "alias/key", "bind_context": "false"}))
+	assert.NoError(t, err)
+
+	copied := stored(item)
+	copied.Key = "OTHER_KEY"
+	deserialized, err := DeserializeItem(NewContext(ctx.KMS, "OTHER_TABLE"), copied, true)
+	assert.NoError(t, err)
+	assert.Equal(t, "SECRET", deserialized.Value)
+}
+
+func TestSerializeKMSContextOptions(t *testing.T) {
+	ctx := NewContext(fake.NewKMS(), "TABLE")
+	item, err := SerializeItem(ctx, newParsedItem("KEY", "SECRET", "kms", map[string]string{"key": "alias/key", "context.env": "prod"}))
+	assert.NoError(t, err)
+
+	changed := stored(item)
+	changed.Value.Serialization.Options = map[string]string{"key": "alias/key", "context.env": "dev", "bind_context": "true"}
+	_, err = DeserializeItem(ctx, changed, true)
+	assert.Error(t, err)
+}
+
+func TestSerializeEnvelopeBinding(t *testing.T) {
+	ctx := NewContext(fake.NewKMS(), "TABLE")
+	item, err := SerializeItem(ctx, newParsedItem("KEY", "SECRET", "envelope", map[string]string{"key": "alias/key"}))
+	assert.NoError(t, err)
+	assert.Equal(t, "true", item.SerializationOptions["bind_context"])
+
+	_, err = DeserializeItem(NewContext(ctx.KMS, "OTHER_TABLE"), stored(item), true)
+	assert.Error(t, err)
+
+	unbound, err := SerializeItem(ctx, newParsedItem("KEY", "SECRET", "envelope", map[string]string{"key": "alias/key", "bind_context": "false"}))
+	assert.NoError(t, err)
+	copied := stored(unbound)
+	copied.Key = "OTHER_KEY"
+	deserialized, err := DeserializeItem(NewContext(ctx.KMS, "OTHER_TABLE"), copied, true)
+	assert.NoError(t, err)
+	assert.Equal(t, "SECRET", deserialized.Value)
+}
+
+func TestSerializeEnvelope(t *testing.T) {
+	svc := fake.NewKMS()
+	ctx := NewContext(svc, "TABLE")
+
+	items := []*models.ParsedItem{}
+	for _, key := range []string{"ONE", "TWO", "THREE"} {
+		items = append(items, newParsedItem(key, strings.Repeat(key, 2000), "envelope", map[string]string{"key": "alias/key"}))
+	}
+	serialized, err := SerializeItems(ctx, items)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, svc.Calls["GenerateDataKey"])
+
+	parsedItems := []*models.ParsedItem{}
+	for _, item := range serialized {
+		parsedItems = append(parsedItems, stored(item))
+	}
+	deserialized, err := DeserializeItems(NewContext(svc, "TABLE"), parsedItems, true)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, svc.Calls["Decrypt"])
+	for i, item := range deserialized {
+		assert.Equal(t, items[i].Value.Value, item.Value)
+	}
+
+	copied := parsedItems[0]
+	copied.Key = "OTHER_KEY"
+	_, err = DeserializeItem(ctx, copied, true)
+	assert.Error(t, err)
+}
+
+func TestSerializeEnvelopeRequiresKey(t *testing.T) {
+	_, err := SerializeItem(NewContext(fake.NewKMS(), "TABLE"), newParsedItem("KEY", "SECRET", "envelope", nil))
+
+	assert.EqualError(t, err, "envelope serialization requires the key option")
+}
+
+func TestSerializeEnvelopeTampered(t *testing.T) {
+	ctx := NewContext(fake.NewKMS(), "TABLE")
+	item, err := SerializeItem(ctx, newParsedItem("KEY", "SECRET", "envelope", map[string]string{"key": "alias/key"}))
+	assert.NoError(t, err)
+
+	envelope, err := decodeBase64(item.Value)
+	assert.NoError(t, err)
+	envelope[len(envelope)-1] ^= 1
+	item.Value = encodeBase64(envelope)
+	_, err = DeserializeItem(ctx, stored(item), true)
+	assert.EqualError(t, err, "invalid envelope: cipher: message authentication failed")
+
+	item.Value = encodeBase64([]byte{0})
+	_, err = DeserializeItem(ctx, stored(item), true)
+	assert.EqualError(t, err, "invalid envelope: unknown version")
+}
+
+func TestSerializeEnvelopeChain(t *testing.T) {
+	value := strings.Repeat("SECRET", 1000)
+	item, deserialized := roundTrip(t, NewContext(fake.NewKMS(), "TABLE"), newParsedItem("KEY", value, "gzip,envelope", map[string]string{"key": "alias/key"}))
+
+	assert.NotContains(t, item.Value, "SECRET")
+	assert.Equal(t, value, deserialized.Value)
+}
+
+func TestReEncryptItem(t *testing.T) {
+	svc := fake.NewKMS()
+	ctx := NewContext(svc, "TABLE")
+	item, err := SerializeItem(ctx, newParsedItem("KEY", "SECRET", "kms", map[string]string{"key": "alias/old"}))
+	assert.NoError(t, err)
+
+	rotated, err := ReEncryptItem(ctx, stored(item), "alias/new")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, svc.Calls["ReEncrypt"])
+	assert.Equal(t, "alias/new", rotated.SerializationOptions["key"])
+
+	deserialized, err := DeserializeItem(ctx, stored(rotated), true)
+	assert.NoError(t, err)
+	assert.Equal(t, "SECRET", deserialized.Value)
+}
+
+func TestKeyMatcher(t *testing.T) {
+	svc := fake.NewKMS()
+	oldArn := "arn:aws:kms:eu-west-1:000000000000:key/old"
+	svc.Aliases["alias/old"] = oldArn
+	ctx := NewContext(svc, "TABLE")
+
+	withAlias, err := SerializeItem(ctx, newParsedItem("ALIAS", "SECRET", "kms", map[string]string{"key": "alias/old"}))
+	assert.NoError(t, err)
+	withArn, err := SerializeItem(ctx, newParsedItem("ARN", "SECRET", "envelope", map[string]string{"key": oldArn}))
+	assert.NoError(t, err)
+	other, err := SerializeItem(ctx, newParsedItem("OTHER", "SECRET", "kms", map[string]string{"key": "alias/other"}))
+	assert.NoError(t, err)
+	// Items written before options were recorded have no key option.
+	legacy, err := SerializeItem(ctx, newParsedItem("LEGACY", "SECRET", "kms", map[string]string{"key": "alias/old", "bind_context": "false"}))
+	assert.NoError(t, err)
+	legacy.SerializationOptions = nil
+
+	for _, keyID := range []string{"alias/old", oldArn} {
+		matcher, err := NewKeyMatcher(ctx, keyID)
+		assert.NoError(t, err)
+		for _, item := range []*models.Item{withAlias, withArn, legacy} {
+			matches, err := matcher.Matches(stored(item))
+			assert.NoError(t, err)
+			assert.True(t, matches, item.Key)
+		}
+		matches, err := matcher.Matches(stored(other))
+		assert.NoError(t, err)
+		assert.False(t, matches)
+	}
+
+	matcher, err := NewKeyMatcher(ctx, "alias/old")
+	assert.NoError(t, err)
+	_, err = matcher.Matches(newParsedItem("BROKEN", "SECRET", "envelope", nil))
+	assert.EqualError(t, err, "no key option for envelope serialization")
+}
@@ -0,0 +1,164 @@
+package table
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// CreateOptions configures the tables created by CreateWithOptions. The
+// history table is created with the same options.
+type CreateOptions struct {
+	// BillingMode is dynamodb.BillingModePayPerRequest or
+	// dynamodb.BillingModeProvisioned.
+	BillingMode string
+	// ReadCapacity and WriteCapacity are only used with provisioned billing.
+	ReadCapacity  int64
+	WriteCapacity int64
+	// KMSKey enables server-side encryption with a customer managed KMS key
+	// instead of the AWS owned key.
+	KMSKey              string
+	Tags                map[string]string
+	PointInTimeRecovery bool
+	DeletionProtection  bool
+}
+
+// DefaultCreateOptions returns the options used by Create: on-demand billing
+// and nothing else.
+func DefaultCreateOptions() *CreateOptions {
+	return &CreateOptions{BillingMode: dynamodb.BillingModePayPerRequest}
+}
+
+func (options *CreateOptions) validate() error {
+	switch options.BillingMode {
+	case dynamodb.BillingModePayPerRequest:
+		if options.ReadCapacity != 0 || options.WriteCapacity != 0 {
+			return fmt.Errorf("Capacity can only be set with %s billing", dynamodb.BillingModeProvisioned)
+		}
+	case dynamodb.BillingModeProvisioned:
+		if options.ReadCapacity < 1 || options.WriteCapacity < 1 {
+			return fmt.Errorf("%s billing requires read and write capacity", dynamodb.BillingModeProvisioned)
+		}
+	default:
+		return fmt.Errorf("Invalid billing mode %s. Expected %s or %s", options.BillingMode, dynamodb.BillingModePayPerRequest, dynamodb.BillingModeProvisioned)
+	}
+	return nil
+}
+
+// Create creates the table and its history table with the default options if
+// they do not exist.
+func (table *Table) Create() error {
+	return table.CreateWithOptions(DefaultCreateOptions())
+}
+
+// CreateWithOptions creates the table and its history table if they do not
+// exist. Existing tables are not modified.
+func (table *Table) CreateWithOptions(options *CreateOptions) error {
+	if err := options.validate(); err != nil {
+		return err
+	}
+	err := table.create(
+		[]*dynamodb.KeySchemaElement{
+			{
+				AttributeName: aws.String("Key"),
+				KeyType:       aws.String("HASH"),
+			},
+		},
+		[]*dynamodb.AttributeDefinition{
+			{
+				AttributeName: aws.String("Key"),
+				AttributeType: aws.String("S"),
+			},
+		},
+		options.withTags(map[string]string{SchemaVersionTag: strconv.Itoa(SchemaVersion)}),
+	)
+	if err != nil {
+		return err
+	}
+	return table.History().Create(options)
+}
+
+// withTags returns a copy of options with extra tags.
+func (options *CreateOptions) withTags(tags map[string]string) *CreateOptions {
+	copied := *options
+	copied.Tags = map[string]string{}
+	for key, value := range options.Tags {
+		copied.Tags[key] = value
+	}
+	for key, value := range tags {
+		copied.Tags[key] = value
+	}
+	return &copied
+}
+
+// Exists returns whether the table exists.
+func (table *Table) Exists() (bool, error) {
+	_, err := table.svc.DescribeTable(&dynamodb.DescribeTableInput{TableName: table.Name})
+	if isResourceNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func isResourceNotFound(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == dynamodb.ErrCodeResourceNotFoundException
+}
+
+func (table *Table) create(keySchema []*dynamodb.KeySchemaElement, attributeDefinitions []*dynamodb.AttributeDefinition, options *CreateOptions) error {
+	exists, err := table.Exists()
+	if err != nil || exists {
+		return err
+	}
+
+	input := &dynamodb.CreateTableInput{TableName: table.Name,
+		KeySchema:            keySchema,
+		AttributeDefinitions: attributeDefinitions,
+		BillingMode:          aws.String(options.BillingMode),
+	}
+	if options.DeletionProtection {
+		input.DeletionProtectionEnabled = aws.Bool(true)
+	}
+	if options.BillingMode == dynamodb.BillingModeProvisioned {
+		input.ProvisionedThroughput = &dynamodb.ProvisionedThroughput{
+			ReadCapacityUnits:  aws.Int64(options.ReadCapacity),
+			WriteCapacityUnits: aws.Int64(options.WriteCapacity),
+		}
+	}
+	if options.KMSKey != "" {
+		input.SSESpecification = &dynamodb.SSESpecification{
+			Enabled:        aws.Bool(true),
+			SSEType:        aws.String(dynamodb.SSETypeKms),
+			KMSMasterKeyId: aws.String(options.KMSKey),
+		}
+	}
+	tagKeys := []string{}
+	for key := range options.Tags {
+		tagKeys = append(tagKeys, key)
+	}
+	sort.Strings(tagKeys)
+	for _, key := range tagKeys {
+		input.Tags = append(input.Tags, &dynamodb.Tag{Key: aws.String(key), Value: aws.String(options.Tags[key])})
+	}
+
+	if _, err = table.svc.CreateTable(input); err != nil {
+		return err
+	}
+	if err = table.svc.WaitUntilTableExists(&dynamodb.DescribeTableInput{TableName: table.Name}); err != nil {
+		return err
+	}
+
+	if options.PointInTimeRecovery {
+		_, err = table.svc.UpdateContinuousBackups(&dynamodb.UpdateContinuousBackupsInput{
+			TableName: table.Name,
+			PointInTimeRecoverySpecification: &dynamodb.PointInTimeRecoverySpecification{
+				PointInTimeRecoveryEnabled: aws.Bool(true),
+			},
+		})
+	}
+	return err
+}
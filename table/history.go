@@ -0,0 +1,178 @@
+package table
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/diasjorge/dynamokv/models"
+)
+
+// HistorySuffix is appended to a table name to get the name of the table
+// keeping the versions of its items.
+const HistorySuffix = "-history"
+
+// History stores every version written to a table, keyed by Key and Version.
+// Values are stored serialized, so encrypted values stay encrypted.
+type History struct {
+	table *Table
+}
+
+func (table *Table) History() *History {
+	return &History{table: &Table{svc: table.svc, Name: aws.String(*table.Name + HistorySuffix), Namespace: table.Namespace}}
+}
+
+// Create creates the history table with options if it does not exist.
+func (history *History) Create(options *CreateOptions) error {
+	return history.table.create(
+		[]*dynamodb.KeySchemaElement{
+			{
+				AttributeName: aws.String("Key"),
+				KeyType:       aws.String("HASH"),
+			},
+			{
+				AttributeName: aws.String("Version"),
+				KeyType:       aws.String("RANGE"),
+			},
+		},
+		[]*dynamodb.AttributeDefinition{
+			{
+				AttributeName: aws.String("Key"),
+				AttributeType: aws.String("S"),
+			},
+			{
+				AttributeName: aws.String("Version"),
+				AttributeType: aws.String("N"),
+			},
+		},
+		options,
+	)
+}
+
+func (history *History) record(items []*models.Item, timestamp time.Time, author string) error {
+	writeRequests := []*dynamodb.WriteRequest{}
+	for _, item := range items {
+		dynamodbItem := history.table.itemToDynamoDB(item)
+		if !timestamp.IsZero() {
+			dynamodbItem["Timestamp"] = &dynamodb.AttributeValue{S: aws.String(timestamp.Format(time.RFC3339))}
+		}
+		if author != "" {
+			dynamodbItem["Author"] = &dynamodb.AttributeValue{S: aws.String(author)}
+		}
+		writeRequests = append(writeRequests, &dynamodb.WriteRequest{
+			PutRequest: &dynamodb.PutRequest{Item: dynamodbItem},
+		})
+	}
+	return history.table.batchWrite(writeRequests)
+}
+
+// List returns the versions of key, oldest first.
+func (history *History) List(key string) ([]*models.HistoryEntry, error) {
+	entries := []*models.HistoryEntry{}
+	var entryErr error
+	err := history.table.svc.QueryPages(
+		&dynamodb.QueryInput{
+			TableName:                 history.table.Name,
+			KeyConditionExpression:    aws.String("#key = :key"),
+			ExpressionAttributeNames:  map[string]*string{"#key": aws.String("Key")},
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":key": {S: aws.String(history.table.storedKey(key))}},
+		},
+		func(resp *dynamodb.QueryOutput, lastPage bool) bool {
+			for _, dynamodbItem := range resp.Items {
+				entry, err := models.NewHistoryEntryFromDynamoDB(dynamodbItem)
+				if err != nil {
+					entryErr = err
+					return false
+				}
+				entry.Item.Key = key
+				entries = append(entries, entry)
+			}
+			return true
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return entries, entryErr
+}
+
+// Get returns a single version of key.
+func (history *History) Get(key string, version int64) (*models.HistoryEntry, error) {
+	resp, err := history.table.svc.GetItem(&dynamodb.GetItemInput{
+		TableName: history.table.Name,
+		Key: map[string]*dynamodb.AttributeValue{
+			"Key":     {S: aws.String(history.table.storedKey(key))},
+			"Version": {N: aws.String(strconv.FormatInt(version, 10))},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Item == nil {
+		return nil, fmt.Errorf("Version %d of Key \"%s\" not found", version, key)
+	}
+	entry, err := models.NewHistoryEntryFromDynamoDB(resp.Item)
+	if err != nil {
+		return nil, err
+	}
+	entry.Item.Key = key
+	return entry, nil
+}
+
+// Exists reports whether the history table exists.
+func (history *History) Exists() (bool, error) {
+	return history.table.Exists()
+}
+
+// latestVersions returns the highest version recorded for each of keys that
+// has history. Every history starts at version 1, so only the keys with a
+// version 1 are queried for their latest version.
+func (history *History) latestVersions(keys []string) (map[string]int64, error) {
+	requestKeys := []map[string]*dynamodb.AttributeValue{}
+	storedKeys := map[string]string{}
+	for _, key := range keys {
+		storedKey := history.table.storedKey(key)
+		storedKeys[storedKey] = key
+		requestKeys = append(requestKeys, map[string]*dynamodb.AttributeValue{
+			"Key":     {S: aws.String(storedKey)},
+			"Version": {N: aws.String("1")},
+		})
+	}
+	dynamodbItems, err := history.table.batchGetItems(requestKeys, []*string{aws.String("Key")})
+	if err != nil {
+		return nil, err
+	}
+
+	versions := map[string]int64{}
+	for _, dynamodbItem := range dynamodbItems {
+		key := storedKeys[aws.StringValue(dynamodbItem["Key"].S)]
+		latest, err := history.latestVersion(key)
+		if err != nil {
+			return nil, err
+		}
+		versions[key] = latest
+	}
+	return versions, nil
+}
+
+// latestVersion returns the highest version recorded for key, or 0.
+func (history *History) latestVersion(key string) (int64, error) {
+	resp, err := history.table.svc.Query(&dynamodb.QueryInput{
+		TableName:                 history.table.Name,
+		KeyConditionExpression:    aws.String("#key = :key"),
+		ExpressionAttributeNames:  map[string]*string{"#key": aws.String("Key")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":key": {S: aws.String(history.table.storedKey(key))}},
+		ProjectionExpression:      aws.String("Version"),
+		ScanIndexForward:          aws.Bool(false),
+		Limit:                     aws.Int64(1),
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(resp.Items) == 0 {
+		return 0, nil
+	}
+	return strconv.ParseInt(aws.StringValue(resp.Items[0]["Version"].N), 10, 64)
+}
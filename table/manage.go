@@ -0,0 +1,324 @@
+package table
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/diasjorge/dynamokv/models"
+)
+
+// SchemaVersionTag is the tag holding the schema version of the tables
+// managed by dynamokv. Tables created by older versions of dynamokv don't
+// have it and are at schema version 0 until migrated.
+const SchemaVersionTag = "dynamokv:schema-version"
+
+// migration upgrades a table to the next schema version.
+type migration struct {
+	description string
+	apply       func(table *Table) error
+}
+
+// migrations are applied in order, migrations[i] upgrading a table from
+// schema version i to i+1.
+var migrations = []migration{
+	{"Create the history table and record unversioned items as version 1", migrateVersions},
+}
+
+// SchemaVersion is the schema version of the tables created by Create.
+var SchemaVersion = len(migrations)
+
+// Description summarizes a table and its settings.
+type Description struct {
+	Name   string
+	Status string
+	// ItemCount and SizeBytes are updated by DynamoDB every six hours.
+	ItemCount           int64
+	SizeBytes           int64
+	SchemaVersion       int
+	BillingMode         string
+	ReadCapacity        int64
+	WriteCapacity       int64
+	KMSKey              string
+	PointInTimeRecovery bool
+	DeletionProtection  bool
+	Tags                map[string]string
+	HasHistory          bool
+}
+
+// Describe returns the description of the table.
+func (table *Table) Describe() (*Description, error) {
+	resp, err := table.svc.DescribeTable(&dynamodb.DescribeTableInput{TableName: table.Name})
+	if err != nil {
+		return nil, err
+	}
+	tableDescription := resp.Table
+	description := &Description{
+		Name:               aws.StringValue(tableDescription.TableName),
+		Status:             aws.StringValue(tableDescription.TableStatus),
+		ItemCount:          aws.Int64Value(tableDescription.ItemCount),
+		SizeBytes:          aws.Int64Value(tableDescription.TableSizeBytes),
+		BillingMode:        dynamodb.BillingModeProvisioned,
+		DeletionProtection: aws.BoolValue(tableDescription.DeletionProtectionEnabled),
+	}
+	if tableDescription.BillingModeSummary != nil {
+		description.BillingMode = aws.StringValue(tableDescription.BillingModeSummary.BillingMode)
+	}
+	if description.BillingMode == dynamodb.BillingModeProvisioned && tableDescription.ProvisionedThroughput != nil {
+		description.ReadCapacity = aws.Int64Value(tableDescription.ProvisionedThroughput.ReadCapacityUnits)
+		description.WriteCapacity = aws.Int64Value(tableDescription.ProvisionedThroughput.WriteCapacityUnits)
+	}
+	if sse := tableDescription.SSEDescription; sse != nil && aws.StringValue(sse.SSEType) == dynamodb.SSETypeKms {
+		description.KMSKey = aws.StringValue(sse.KMSMasterKeyArn)
+	}
+
+	description.Tags, err = table.tags(tableDescription.TableArn)
+	if err != nil {
+		return nil, err
+	}
+	description.SchemaVersion, err = schemaVersion(description.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	backups, err := table.svc.DescribeContinuousBackups(&dynamodb.DescribeContinuousBackupsInput{TableName: table.Name})
+	if err != nil {
+		return nil, err
+	}
+	if recovery := backups.ContinuousBackupsDescription.PointInTimeRecoveryDescription; recovery != nil {
+		description.PointInTimeRecovery = aws.StringValue(recovery.PointInTimeRecoveryStatus) == dynamodb.PointInTimeRecoveryStatusEnabled
+	}
+
+	description.HasHistory, err = table.History().table.Exists()
+	if err != nil {
+		return nil, err
+	}
+	return description, nil
+}
+
+func (table *Table) tags(arn *string) (map[string]string, error) {
+	tags := map[string]string{}
+	input := &dynamodb.ListTagsOfResourceInput{ResourceArn: arn}
+	for {
+		resp, err := table.svc.ListTagsOfResource(input)
+		if err != nil {
+			return nil, err
+		}
+		for _, tag := range resp.Tags {
+			tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+		}
+		if resp.NextToken == nil {
+			return tags, nil
+		}
+		input.NextToken = resp.NextToken
+	}
+}
+
+func schemaVersion(tags map[string]string) (int, error) {
+	value, ok := tags[SchemaVersionTag]
+	if !ok {
+		return 0, nil
+	}
+	version, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid %s tag: %s", SchemaVersionTag, value)
+	}
+	return version, nil
+}
+
+// List returns the names of the tables managed by dynamokv, those with the
+// SchemaVersionTag. Tables created by older versions are listed once
+// migrated.
+func List(svc dynamodbiface.DynamoDBAPI) ([]string, error) {
+	names := []string{}
+	err := svc.ListTablesPages(&dynamodb.ListTablesInput{}, func(resp *dynamodb.ListTablesOutput, lastPage bool) bool {
+		for _, name := range resp.TableNames {
+			names = append(names, aws.StringValue(name))
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	managed := []string{}
+	for _, name := range names {
+		if strings.HasSuffix(name, HistorySuffix) {
+			continue
+		}
+		table := NewTable(svc, name)
+		resp, err := svc.DescribeTable(&dynamodb.DescribeTableInput{TableName: table.Name})
+		if err != nil {
+			return nil, err
+		}
+		tags, err := table.tags(resp.Table.TableArn)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := tags[SchemaVersionTag]; ok {
+			managed = append(managed, name)
+		}
+	}
+	return managed, nil
+}
+
+// DeleteTables deletes the table and its history table.
+func (table *Table) DeleteTables() error {
+	for _, t := range []*Table{table, table.History().table} {
+		_, err := t.svc.DeleteTable(&dynamodb.DeleteTableInput{TableName: t.Name})
+		if isResourceNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := t.svc.WaitUntilTableNotExists(&dynamodb.DescribeTableInput{TableName: t.Name}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Migrate upgrades the table to SchemaVersion and returns the descriptions
+// of the migrations applied.
+func (table *Table) Migrate() ([]string, error) {
+	resp, err := table.svc.DescribeTable(&dynamodb.DescribeTableInput{TableName: table.Name})
+	if err != nil {
+		return nil, err
+	}
+	tags, err := table.tags(resp.Table.TableArn)
+	if err != nil {
+		return nil, err
+	}
+	version, err := schemaVersion(tags)
+	if err != nil {
+		return nil, err
+	}
+	if version > SchemaVersion {
+		return nil, fmt.Errorf("Table %s has schema version %d, newer than %d. Upgrade dynamokv", *table.Name, version, SchemaVersion)
+	}
+
+	applied := []string{}
+	for ; version < SchemaVersion; version++ {
+		if err := migrations[version].apply(table); err != nil {
+			return applied, err
+		}
+		_, err := table.svc.TagResource(&dynamodb.TagResourceInput{
+			ResourceArn: resp.Table.TableArn,
+			Tags: []*dynamodb.Tag{
+				{Key: aws.String(SchemaVersionTag), Value: aws.String(strconv.Itoa(version + 1))},
+			},
+		})
+		if err != nil {
+			return applied, err
+		}
+		applied = append(applied, migrations[version].description)
+	}
+	return applied, nil
+}
+
+// SeparatorKeys returns the sorted keys, in every namespace, that contain
+// the models.NamespaceSeparator. Keys written before namespaces existed are
+// read as keys of a namespace, and are hidden from the default one.
+func (table *Table) SeparatorKeys() ([]string, error) {
+	all := &Table{svc: table.svc, Name: table.Name, allNamespaces: true}
+	parsedItems, err := all.Read()
+	if err != nil {
+		return nil, err
+	}
+	keys := []string{}
+	for _, parsedItem := range parsedItems {
+		if strings.Contains(parsedItem.Key, models.NamespaceSeparator) {
+			keys = append(keys, parsedItem.Key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// migrateVersions creates the history table and gives version 1 to the items
+// written before versioning, in every namespace. Items written or deleted
+// meanwhile are left as they are.
+func migrateVersions(table *Table) error {
+	if err := table.History().Create(DefaultCreateOptions()); err != nil {
+		return err
+	}
+
+	all := &Table{svc: table.svc, Name: table.Name, allNamespaces: true}
+	parsedItems, err := all.Read()
+	if err != nil {
+		return err
+	}
+	items := []*models.Item{}
+	current := map[string]*models.ParsedItem{}
+	for _, parsedItem := range parsedItems {
+		if parsedItem.Version > 0 {
+			continue
+		}
+		current[parsedItem.Key] = parsedItem
+		items = append(items, &models.Item{
+			Key:                  parsedItem.Key,
+			Value:                parsedItem.Value.Value,
+			Serialization:        parsedItem.Value.Serialization.Type,
+			SerializationOptions: parsedItem.Value.Serialization.Options,
+			Version:              1,
+			Metadata:             parsedItem.Metadata,
+		})
+	}
+	written, err := all.putUnversioned(items, current)
+	if historyErr := all.History().record(written, time.Time{}, ""); err == nil {
+		err = historyErr
+	}
+	return err
+}
+
+// putUnversioned writes items in transactions, each item conditioned on its
+// stored item still having no Version, and returns the items written. Items
+// that were written or deleted since current was read are skipped.
+func (table *Table) putUnversioned(items []*models.Item, current map[string]*models.ParsedItem) ([]*models.Item, error) {
+	written := []*models.Item{}
+	pending := items
+	var lastErr error
+	for attempt := 0; len(pending) > 0; attempt++ {
+		if attempt > 0 {
+			if attempt > maxWriteRetries {
+				return written, &WriteError{Keys: itemKeys(pending), Err: lastErr}
+			}
+			time.Sleep(retryDelay(attempt))
+		}
+
+		retry := []*models.Item{}
+		for start := 0; start < len(pending); start += batchWriteLimit {
+			end := start + batchWriteLimit
+			if end > len(pending) {
+				end = len(pending)
+			}
+			chunk := pending[start:end]
+
+			err := table.transactPutItems(chunk, current)
+			if canceled, ok := err.(*dynamodb.TransactionCanceledException); ok {
+				changed := map[string]bool{}
+				for _, key := range conditionalCheckFailures(chunk, canceled) {
+					changed[key] = true
+				}
+				for _, item := range chunk {
+					if !changed[item.Key] {
+						retry = append(retry, item)
+					}
+				}
+				lastErr = err
+				continue
+			} else if err != nil {
+				return written, &WriteError{Keys: append(itemKeys(retry), itemKeys(pending[start:])...), Err: err}
+			}
+			written = append(written, chunk...)
+		}
+		pending = retry
+	}
+	return written, nil
+}
@@ -2,94 +2,540 @@ package table
 
 import (
 	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 	"github.com/diasjorge/dynamokv/models"
 )
 
+// itemAttributes lists the attributes fetched for every item.
+var itemAttributes = []*string{
+	aws.String("Key"),
+	aws.String("Value"),
+	aws.String("Serialization"),
+	aws.String("SerializationOptions"),
+	aws.String("Version"),
+	aws.String("UpdatedAt"),
+	aws.String("UpdatedBy"),
+	aws.String("Source"),
+}
+
 type Table struct {
-	svc  *dynamodb.DynamoDB
+	svc  dynamodbiface.DynamoDBAPI
 	Name *string
+	// Author is recorded in the history of every item written.
+	Author string
+	// Source is recorded on every item written, e.g. the command and file.
+	Source string
+	// Namespace scopes the keys read and written. Keys are stored prefixed
+	// by the namespace, see models.NamespacedKey.
+	Namespace string
+	// allNamespaces makes Read return every item with its stored Key.
+	allNamespaces bool
 }
 
-func NewTable(svc *dynamodb.DynamoDB, name string) *Table {
+func NewTable(svc dynamodbiface.DynamoDBAPI, name string) *Table {
 	return &Table{
 		svc:  svc,
 		Name: aws.String(name),
 	}
 }
 
-func (table *Table) Create() error {
-	_, err := table.svc.DescribeTable(&dynamodb.DescribeTableInput{TableName: table.Name})
-	if err == nil {
-		return nil
+// WriteError is returned by Write when some items could not be stored,
+// either because DynamoDB kept returning them as unprocessed or because a
+// request failed. Keys lists every item that was not written, or whose
+// history could not be recorded, and Err the cause. Items not listed were
+// written.
+type WriteError struct {
+	Keys []string
+	Err  error
+}
+
+func (e *WriteError) Error() string {
+	msg := fmt.Sprintf("failed to write %d item(s): %s", len(e.Keys), strings.Join(e.Keys, ", "))
+	if e.Err != nil {
+		msg = fmt.Sprintf("%s: %v", msg, e.Err)
 	}
-	_, err = table.svc.CreateTable(&dynamodb.CreateTableInput{TableName: table.Name,
-		KeySchema: []*dynamodb.KeySchemaElement{
-			{
-				AttributeName: aws.String("Key"),
-				KeyType:       aws.String("HASH"),
-			},
+	return msg
+}
+
+// batchWriteLimit is the maximum number of requests DynamoDB accepts in a
+// single BatchWriteItem call.
+const batchWriteLimit = 25
+
+var (
+	maxWriteRetries = 8
+	baseRetryDelay  = 50 * time.Millisecond
+	maxRetryDelay   = 5 * time.Second
+)
+
+// ConflictError is returned by conditional writes when the stored version of
+// some keys is not the expected one.
+type ConflictError struct {
+	Keys []string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("version conflict for %d key(s): %s", len(e.Keys), strings.Join(e.Keys, ", "))
+}
+
+// Write stores items, setting the Version of each item to the next version of
+// its Key, and records them in the history table. Items are written in
+// transactions of up to batchWriteLimit items, each conditioned on the
+// versions read, so concurrent writers never overwrite each other: items
+// that changed meanwhile are read and written again.
+func (table *Table) Write(items []*models.Item) error {
+	return table.write(items, nil)
+}
+
+// WriteIfVersion stores items like Write, but only if the stored Version of
+// each Key in expected matches. An expected Version of 0 requires the Key to
+// be absent, and items written before versioning count as Version 1. Items
+// are written in transactions of up to transactWriteLimit items. If any
+// version does not match before the first transaction is applied, a
+// ConflictError is returned and no item is written. If a later transaction
+// fails, a WriteError lists the items that were not written and wraps the
+// cause, such as a ConflictError.
+func (table *Table) WriteIfVersion(items []*models.Item, expected map[string]int64) error {
+	return table.write(items, expected)
+}
+
+// SetIfVersion stores a single item if its stored Version is version, or if
+// it does not exist when version is 0.
+func (table *Table) SetIfVersion(item *models.Item, version int64) error {
+	return table.WriteIfVersion([]*models.Item{item}, map[string]int64{item.Key: version})
+}
+
+func (table *Table) write(items []*models.Item, expected map[string]int64) error {
+	chunkSize := batchWriteLimit
+	if expected != nil {
+		chunkSize = transactWriteLimit
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+
+	pending := items
+	written := false
+	var lastErr error
+	for attempt := 0; len(pending) > 0; attempt++ {
+		if attempt > 0 {
+			if attempt > maxWriteRetries {
+				return &WriteError{Keys: itemKeys(pending), Err: lastErr}
+			}
+			time.Sleep(retryDelay(attempt))
+		}
+
+		current, err := table.batchGet(itemKeys(pending))
+		if err != nil {
+			return err
+		}
+		if expected != nil {
+			if err := checkVersions(pending, current, expected); err != nil {
+				return partialWriteError(written, itemKeys(pending), err)
+			}
+		}
+		unversioned, err := table.assignVersions(pending, current)
+		if err != nil {
+			return err
+		}
+		for _, item := range pending {
+			item.Metadata = models.Metadata{UpdatedAt: now, UpdatedBy: table.Author, Source: table.Source}
+		}
+
+		retry := []*models.Item{}
+		for start := 0; start < len(pending); start += chunkSize {
+			end := start + chunkSize
+			if end > len(pending) {
+				end = len(pending)
+			}
+			chunk := pending[start:end]
+			unwritten := append(itemKeys(retry), itemKeys(pending[start:])...)
+
+			err := table.transactPutItems(chunk, current)
+			if canceled, ok := err.(*dynamodb.TransactionCanceledException); ok {
+				if conflicts := conditionalCheckFailures(chunk, canceled); expected != nil && len(conflicts) > 0 {
+					return partialWriteError(written, unwritten, &ConflictError{Keys: conflicts})
+				}
+				lastErr = err
+				retry = append(retry, chunk...)
+				continue
+			} else if err != nil {
+				return &WriteError{Keys: unwritten, Err: err}
+			}
+			written = true
+
+			if err := table.recordHistory(chunk, unversioned, now); err != nil {
+				return &WriteError{Keys: unwritten, Err: fmt.Errorf("recording history: %v", err)}
+			}
+		}
+		pending = retry
+	}
+	return nil
+}
+
+// partialWriteError returns err if no item was written yet, and otherwise a
+// WriteError listing the keys that were not written.
+func partialWriteError(written bool, keys []string, err error) error {
+	if !written {
+		return err
+	}
+	return &WriteError{Keys: keys, Err: err}
+}
+
+func itemKeys(items []*models.Item) []string {
+	keys := []string{}
+	for _, item := range items {
+		keys = append(keys, item.Key)
+	}
+	return keys
+}
+
+// checkVersions returns a ConflictError listing the items whose current
+// version is not the expected one.
+func checkVersions(items []*models.Item, current map[string]*models.ParsedItem, expected map[string]int64) error {
+	conflicts := []string{}
+	for _, item := range items {
+		version, ok := expected[item.Key]
+		if !ok {
+			continue
+		}
+		existing, exists := current[item.Key]
+		if !exists && version != 0 || exists && storedVersion(existing) != version {
+			conflicts = append(conflicts, item.Key)
+		}
+	}
+	if len(conflicts) > 0 {
+		return &ConflictError{Keys: conflicts}
+	}
+	return nil
+}
+
+// storedVersion returns the version of an existing item. Items written
+// before versioning have no Version and count as version 1.
+func storedVersion(existing *models.ParsedItem) int64 {
+	if existing.Version == 0 {
+		return 1
+	}
+	return existing.Version
+}
+
+// assignVersions sets the Version of each item to the next version of its
+// Key. It returns the items written before versioning that items replace,
+// indexed by Key, to be recorded in the history as version 1.
+func (table *Table) assignVersions(items []*models.Item, current map[string]*models.ParsedItem) (map[string]*models.Item, error) {
+	// New keys continue the versions of a deleted item with the same Key.
+	newKeys := []string{}
+	for _, item := range items {
+		if _, ok := current[item.Key]; !ok {
+			newKeys = append(newKeys, item.Key)
+		}
+	}
+	latest, err := table.History().latestVersions(newKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	unversioned := map[string]*models.Item{}
+	for _, item := range items {
+		existing, ok := current[item.Key]
+		switch {
+		case !ok:
+			item.Version = latest[item.Key] + 1
+		case existing.Version == 0:
+			unversioned[item.Key] = &models.Item{
+				Key:                  existing.Key,
+				Value:                existing.Value.Value,
+				Serialization:        existing.Value.Serialization.Type,
+				SerializationOptions: existing.Value.Serialization.Options,
+				Version:              1,
+			}
+			item.Version = 2
+		default:
+			item.Version = existing.Version + 1
+		}
+	}
+	return unversioned, nil
+}
+
+// recordHistory records the written items, and the unversioned items they
+// replaced, in the history table.
+func (table *Table) recordHistory(written []*models.Item, unversioned map[string]*models.Item, now time.Time) error {
+	replaced := []*models.Item{}
+	for _, item := range written {
+		if previous, ok := unversioned[item.Key]; ok {
+			replaced = append(replaced, previous)
+		}
+	}
+	history := table.History()
+	if err := history.record(replaced, time.Time{}, ""); err != nil {
+		return err
+	}
+	return history.record(written, now, table.Author)
+}
+
+// transactWriteLimit is the maximum number of items DynamoDB accepts in a
+// single TransactWriteItems call.
+const transactWriteLimit = 100
+
+// transactPutItems writes items in a single transaction. Each item is
+// conditioned on the item read in current, so the transaction is canceled if
+// any of them changed or, if absent, was created.
+func (table *Table) transactPutItems(items []*models.Item, current map[string]*models.ParsedItem) error {
+	transactItems := []*dynamodb.TransactWriteItem{}
+	for _, item := range items {
+		put := &dynamodb.Put{TableName: table.Name, Item: table.itemToDynamoDB(item)}
+		existing, ok := current[item.Key]
+		switch {
+		case !ok:
+			put.ConditionExpression = aws.String("attribute_not_exists(#key)")
+			put.ExpressionAttributeNames = map[string]*string{"#key": aws.String("Key")}
+		case existing.Version == 0:
+			put.ConditionExpression = aws.String("attribute_exists(#key) AND attribute_not_exists(#version)")
+			put.ExpressionAttributeNames = map[string]*string{"#key": aws.String("Key"), "#version": aws.String("Version")}
+		default:
+			put.ConditionExpression = aws.String("#version = :version")
+			put.ExpressionAttributeNames = map[string]*string{"#version": aws.String("Version")}
+			put.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
+				":version": {N: aws.String(strconv.FormatInt(existing.Version, 10))},
+			}
+		}
+		transactItems = append(transactItems, &dynamodb.TransactWriteItem{Put: put})
+	}
+
+	_, err := table.svc.TransactWriteItems(&dynamodb.TransactWriteItemsInput{TransactItems: transactItems})
+	return err
+}
+
+// conditionalCheckFailures returns the keys of items whose condition failed
+// in a canceled transaction.
+func conditionalCheckFailures(items []*models.Item, canceled *dynamodb.TransactionCanceledException) []string {
+	conflicts := []string{}
+	for i, reason := range canceled.CancellationReasons {
+		if i < len(items) && aws.StringValue(reason.Code) == "ConditionalCheckFailed" {
+			conflicts = append(conflicts, items[i].Key)
+		}
+	}
+	return conflicts
+}
+
+// storedKey returns the Key attribute of key in the namespace of the table.
+func (table *Table) storedKey(key string) string {
+	return models.NamespacedKey(table.Namespace, key)
+}
+
+// namespaceKey returns the key name of a Key attribute and whether it belongs
+// to the namespace of the table. Keys in the default namespace never contain
+// the models.NamespaceSeparator.
+func (table *Table) namespaceKey(storedKey string) (string, bool) {
+	if table.allNamespaces {
+		return storedKey, true
+	}
+	if table.Namespace == "" {
+		return storedKey, !strings.Contains(storedKey, models.NamespaceSeparator)
+	}
+	prefix := table.Namespace + models.NamespaceSeparator
+	if !strings.HasPrefix(storedKey, prefix) {
+		return storedKey, false
+	}
+	return strings.TrimPrefix(storedKey, prefix), true
+}
+
+// parseItem parses an item of the table, returning nil if it belongs to
+// another namespace.
+func (table *Table) parseItem(dynamodbItem map[string]*dynamodb.AttributeValue) (*models.ParsedItem, error) {
+	item, err := models.NewParsedItemFromDynamoDB(dynamodbItem)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := table.namespaceKey(item.Key)
+	if !ok {
+		return nil, nil
+	}
+	item.Key = key
+	return item, nil
+}
+
+func (table *Table) itemToDynamoDB(item *models.Item) map[string]*dynamodb.AttributeValue {
+	dynamodbItem := map[string]*dynamodb.AttributeValue{
+		"Key": {
+			S: aws.String(table.storedKey(item.Key)),
 		},
-		AttributeDefinitions: []*dynamodb.AttributeDefinition{
-			{
-				AttributeName: aws.String("Key"),
-				AttributeType: aws.String("S"),
-			},
+		"Value": {
+			S: aws.String(item.Value),
 		},
-		ProvisionedThroughput: &dynamodb.ProvisionedThroughput{
-			ReadCapacityUnits:  aws.Int64(2),
-			WriteCapacityUnits: aws.Int64(1),
+		"Serialization": {
+			S: aws.String(item.Serialization),
 		},
-	})
-	if err != nil {
-		return err
 	}
-	if err = table.svc.WaitUntilTableExists(&dynamodb.DescribeTableInput{TableName: table.Name}); err != nil {
-		return err
+	if item.Version > 0 {
+		dynamodbItem["Version"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(item.Version, 10))}
+	}
+	if len(item.SerializationOptions) > 0 {
+		options := map[string]*dynamodb.AttributeValue{}
+		for name, value := range item.SerializationOptions {
+			options[name] = &dynamodb.AttributeValue{S: aws.String(value)}
+		}
+		dynamodbItem["SerializationOptions"] = &dynamodb.AttributeValue{M: options}
+	}
+	if !item.UpdatedAt.IsZero() {
+		dynamodbItem["UpdatedAt"] = &dynamodb.AttributeValue{S: aws.String(item.UpdatedAt.Format(time.RFC3339))}
+	}
+	if item.UpdatedBy != "" {
+		dynamodbItem["UpdatedBy"] = &dynamodb.AttributeValue{S: aws.String(item.UpdatedBy)}
+	}
+	if item.Source != "" {
+		dynamodbItem["Source"] = &dynamodb.AttributeValue{S: aws.String(item.Source)}
+	}
+	return dynamodbItem
+}
+
+// batchWrite sends writeRequests in chunks of batchWriteLimit, resubmitting
+// unprocessed requests with exponential backoff.
+func (table *Table) batchWrite(writeRequests []*dynamodb.WriteRequest) error {
+	var failed []string
+	var lastErr error
+
+	for start := 0; start < len(writeRequests); start += batchWriteLimit {
+		end := start + batchWriteLimit
+		if end > len(writeRequests) {
+			end = len(writeRequests)
+		}
+		pending, err := table.writeChunk(writeRequests[start:end])
+		if err != nil {
+			lastErr = err
+		}
+		for _, request := range pending {
+			failed = append(failed, table.writeRequestKey(request))
+		}
+	}
+
+	if len(failed) > 0 {
+		return &WriteError{Keys: failed, Err: lastErr}
 	}
 	return nil
 }
 
-func (table *Table) Write(items []*models.Item) error {
-	writeRequests := []*dynamodb.WriteRequest{}
-	for _, item := range items {
-		writeRequests = append(writeRequests, &dynamodb.WriteRequest{
-			PutRequest: &dynamodb.PutRequest{
-				Item: map[string]*dynamodb.AttributeValue{
-					"Key": {
-						S: aws.String(item.Key),
-					},
-					"Value": {
-						S: aws.String(item.Value),
-					},
-					"Serialization": {
-						S: aws.String(item.Serialization),
-					},
-				},
+// writeChunk writes a single chunk and returns the requests that never
+// succeeded.
+func (table *Table) writeChunk(pending []*dynamodb.WriteRequest) ([]*dynamodb.WriteRequest, error) {
+	for attempt := 0; len(pending) > 0; attempt++ {
+		if attempt > 0 {
+			if attempt > maxWriteRetries {
+				return pending, nil
+			}
+			time.Sleep(retryDelay(attempt))
+		}
+		resp, err := table.svc.BatchWriteItem(&dynamodb.BatchWriteItemInput{
+			RequestItems: map[string][]*dynamodb.WriteRequest{
+				*table.Name: pending,
 			},
 		})
+		if err != nil {
+			return pending, err
+		}
+		pending = resp.UnprocessedItems[*table.Name]
 	}
-	_, err := table.svc.BatchWriteItem(&dynamodb.BatchWriteItemInput{
-		RequestItems: map[string][]*dynamodb.WriteRequest{
-			*table.Name: writeRequests,
-		},
-	})
+	return nil, nil
+}
+
+// batchGetLimit is the maximum number of keys DynamoDB accepts in a single
+// BatchGetItem call.
+const batchGetLimit = 100
+
+// batchGet returns the existing items for keys, indexed by Key.
+func (table *Table) batchGet(keys []string) (map[string]*models.ParsedItem, error) {
+	requestKeys := []map[string]*dynamodb.AttributeValue{}
+	for _, key := range keys {
+		requestKeys = append(requestKeys, map[string]*dynamodb.AttributeValue{"Key": {S: aws.String(table.storedKey(key))}})
+	}
+	dynamodbItems, err := table.batchGetItems(requestKeys, itemAttributes)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+
+	items := map[string]*models.ParsedItem{}
+	for _, dynamodbItem := range dynamodbItems {
+		item, err := table.parseItem(dynamodbItem)
+		if err != nil {
+			return nil, err
+		}
+		if item != nil {
+			items[item.Key] = item
+		}
+	}
+	return items, nil
+}
+
+// batchGetItems reads the items with the given primary keys, retrying
+// unprocessed keys, and returns the existing ones as stored.
+func (table *Table) batchGetItems(requestKeys []map[string]*dynamodb.AttributeValue, attributes []*string) ([]map[string]*dynamodb.AttributeValue, error) {
+	dynamodbItems := []map[string]*dynamodb.AttributeValue{}
+	for start := 0; start < len(requestKeys); start += batchGetLimit {
+		end := start + batchGetLimit
+		if end > len(requestKeys) {
+			end = len(requestKeys)
+		}
+		pending := &dynamodb.KeysAndAttributes{Keys: requestKeys[start:end], AttributesToGet: attributes}
+
+		for attempt := 0; pending != nil && len(pending.Keys) > 0; attempt++ {
+			if attempt > 0 {
+				if attempt > maxWriteRetries {
+					return nil, fmt.Errorf("failed to read %d item(s) from %s", len(pending.Keys), *table.Name)
+				}
+				time.Sleep(retryDelay(attempt))
+			}
+			resp, err := table.svc.BatchGetItem(&dynamodb.BatchGetItemInput{
+				RequestItems: map[string]*dynamodb.KeysAndAttributes{*table.Name: pending},
+			})
+			if err != nil {
+				return nil, err
+			}
+			dynamodbItems = append(dynamodbItems, resp.Responses[*table.Name]...)
+			pending = resp.UnprocessedKeys[*table.Name]
+		}
+	}
+	return dynamodbItems, nil
+}
+
+// GetItems returns the existing items for keys, indexed by Key. Missing keys
+// are not in the result.
+func (table *Table) GetItems(keys []string) (map[string]*models.ParsedItem, error) {
+	return table.batchGet(keys)
+}
+
+// retryDelay returns an exponential backoff delay with full jitter.
+func retryDelay(attempt int) time.Duration {
+	delay := baseRetryDelay << uint(attempt-1)
+	if delay <= 0 || delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return time.Duration(rand.Int63n(int64(delay))) + 1
+}
+
+func (table *Table) writeRequestKey(request *dynamodb.WriteRequest) string {
+	var attributes map[string]*dynamodb.AttributeValue
+	switch {
+	case request.PutRequest != nil:
+		attributes = request.PutRequest.Item
+	case request.DeleteRequest != nil:
+		attributes = request.DeleteRequest.Key
+	}
+	if key, ok := attributes["Key"]; ok && key.S != nil {
+		name, _ := table.namespaceKey(*key.S)
+		return name
+	}
+	return ""
 }
 
 func (table *Table) Read() ([]*models.ParsedItem, error) {
 	params := &dynamodb.ScanInput{
-		TableName: table.Name,
-		AttributesToGet: []*string{
-			aws.String("Key"),
-			aws.String("Value"),
-			aws.String("Serialization"),
-		},
+		TableName:       table.Name,
+		AttributesToGet: itemAttributes,
 	}
 	items := []*models.ParsedItem{}
 
@@ -97,8 +543,8 @@ func (table *Table) Read() ([]*models.ParsedItem, error) {
 		params,
 		func(resp *dynamodb.ScanOutput, lastPage bool) bool {
 			for _, dynamodbItem := range resp.Items {
-				item, err := models.NewParsedItemFromDynamoDB(dynamodbItem)
-				if err != nil {
+				item, err := table.parseItem(dynamodbItem)
+				if err != nil || item == nil {
 					continue
 				}
 				items = append(items, item)
@@ -115,18 +561,14 @@ func (table *Table) Read() ([]*models.ParsedItem, error) {
 
 func (table *Table) Get(key string) (*models.ParsedItem, error) {
 	params := &dynamodb.QueryInput{
-		TableName: table.Name,
-		AttributesToGet: []*string{
-			aws.String("Key"),
-			aws.String("Value"),
-			aws.String("Serialization"),
-		},
+		TableName:       table.Name,
+		AttributesToGet: itemAttributes,
 		KeyConditions: map[string]*dynamodb.Condition{
 			"Key": {
 				ComparisonOperator: aws.String("EQ"),
 				AttributeValueList: []*dynamodb.AttributeValue{
 					{
-						S: aws.String(key),
+						S: aws.String(table.storedKey(key)),
 					},
 				},
 			},
@@ -142,9 +584,59 @@ func (table *Table) Get(key string) (*models.ParsedItem, error) {
 		return nil, fmt.Errorf("error querying for Item with Key \"%v\": %v occurrences found", key, *resp.Count)
 	}
 
-	return models.NewParsedItemFromDynamoDB(resp.Items[0])
+	item, err := models.NewParsedItemFromDynamoDB(resp.Items[0])
+	if err != nil {
+		return nil, err
+	}
+	item.Key = key
+	return item, nil
 }
 
 func (table *Table) Set(item *models.Item) error {
 	return table.Write([]*models.Item{item})
 }
+
+// KeyNotFoundError is returned when deleting a Key that is not in the table.
+type KeyNotFoundError struct {
+	Key string
+}
+
+func (e *KeyNotFoundError) Error() string {
+	return fmt.Sprintf("Key \"%s\" not found", e.Key)
+}
+
+// Delete removes a single item, returning a KeyNotFoundError if it does not exist.
+func (table *Table) Delete(key string) error {
+	_, err := table.svc.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: table.Name,
+		Key: map[string]*dynamodb.AttributeValue{
+			"Key": {
+				S: aws.String(table.storedKey(key)),
+			},
+		},
+		ConditionExpression:      aws.String("attribute_exists(#key)"),
+		ExpressionAttributeNames: map[string]*string{"#key": aws.String("Key")},
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return &KeyNotFoundError{Key: key}
+	}
+	return err
+}
+
+// BatchDelete removes several items using batched requests. Keys that do not
+// exist are ignored.
+func (table *Table) BatchDelete(keys []string) error {
+	writeRequests := []*dynamodb.WriteRequest{}
+	for _, key := range keys {
+		writeRequests = append(writeRequests, &dynamodb.WriteRequest{
+			DeleteRequest: &dynamodb.DeleteRequest{
+				Key: map[string]*dynamodb.AttributeValue{
+					"Key": {
+						S: aws.String(table.storedKey(key)),
+					},
+				},
+			},
+		})
+	}
+	return table.batchWrite(writeRequests)
+}
@@ -0,0 +1,618 @@
+package table
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/diasjorge/dynamokv/fake"
+	"github.com/diasjorge/dynamokv/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	baseRetryDelay = time.Nanosecond
+	maxRetryDelay = time.Nanosecond
+}
+
+func newTestTable(t *testing.T) (*Table, *fake.DynamoDB) {
+	svc := fake.NewDynamoDB()
+	table := NewTable(svc, "TEST_TABLE")
+	if err := table.Create(); err != nil {
+		t.Fatal(err)
+	}
+	return table, svc
+}
+
+func testItems(count int) []*models.Item {
+	items := []*models.Item{}
+	for i := 0; i < count; i++ {
+		items = append(items, &models.Item{
+			Key:           fmt.Sprintf("KEY_%02d", i),
+			Value:         fmt.Sprintf("VALUE_%02d", i),
+			Serialization: "plain",
+		})
+	}
+	return items
+}
+
+func TestWriteAndRead(t *testing.T) {
+	table, _ := newTestTable(t)
+
+	err := table.Write(testItems(60))
+	assert.NoError(t, err)
+
+	parsedItems, err := table.Read()
+	assert.NoError(t, err)
+	assert.Len(t, parsedItems, 60)
+	assert.Equal(t, "KEY_00", parsedItems[0].Key)
+	assert.Equal(t, "VALUE_00", parsedItems[0].Value.Value)
+}
+
+func TestBatchWriteRetriesUnprocessedItems(t *testing.T) {
+	table, svc := newTestTable(t)
+	svc.UnprocessedWrites = 3
+
+	err := table.batchWrite(putRequests(table, testItems(5)))
+	assert.NoError(t, err)
+
+	parsedItems, err := table.Read()
+	assert.NoError(t, err)
+	assert.Len(t, parsedItems, 5)
+}
+
+func TestBatchWriteReportsUnprocessedItems(t *testing.T) {
+	table, svc := newTestTable(t)
+	svc.UnprocessedWrites = 100
+
+	err := table.batchWrite(putRequests(table, testItems(3)))
+
+	writeErr, ok := err.(*WriteError)
+	if assert.True(t, ok) {
+		assert.Equal(t, []string{"KEY_00", "KEY_01", "KEY_02"}, writeErr.Keys)
+	}
+}
+
+func putRequests(table *Table, items []*models.Item) []*dynamodb.WriteRequest {
+	writeRequests := []*dynamodb.WriteRequest{}
+	for _, item := range items {
+		writeRequests = append(writeRequests, &dynamodb.WriteRequest{
+			PutRequest: &dynamodb.PutRequest{Item: table.itemToDynamoDB(item)},
+		})
+	}
+	return writeRequests
+}
+
+func TestWriteRetriesConflictingTransactions(t *testing.T) {
+	table, svc := newTestTable(t)
+	svc.ConflictingTransactions = 3
+
+	err := table.Write(testItems(30))
+	assert.NoError(t, err)
+
+	parsedItems, err := table.Read()
+	assert.NoError(t, err)
+	assert.Len(t, parsedItems, 30)
+	entries, err := table.History().List("KEY_00")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestWriteReportsConflictingTransactions(t *testing.T) {
+	table, svc := newTestTable(t)
+	svc.ConflictingTransactions = 100
+
+	err := table.Write(testItems(3))
+
+	writeErr, ok := err.(*WriteError)
+	if assert.True(t, ok) {
+		assert.Equal(t, []string{"KEY_00", "KEY_01", "KEY_02"}, writeErr.Keys)
+	}
+}
+
+func TestSerializationOptions(t *testing.T) {
+	table, _ := newTestTable(t)
+
+	err := table.Set(&models.Item{
+		Key:                  "KEY",
+		Value:                "VALUE",
+		Serialization:        "kms",
+		SerializationOptions: map[string]string{"key": "alias/key"},
+	})
+	assert.NoError(t, err)
+
+	parsedItem, err := table.Get("KEY")
+	assert.NoError(t, err)
+	assert.Equal(t, &models.Serialization{Type: "kms", Options: map[string]string{"key": "alias/key"}}, parsedItem.Value.Serialization)
+}
+
+func TestDelete(t *testing.T) {
+	table, _ := newTestTable(t)
+	table.Write(testItems(3))
+
+	assert.NoError(t, table.Delete("KEY_00"))
+	assert.Equal(t, &KeyNotFoundError{Key: "KEY_00"}, table.Delete("KEY_00"))
+
+	assert.NoError(t, table.BatchDelete([]string{"KEY_01", "KEY_02"}))
+
+	parsedItems, err := table.Read()
+	assert.NoError(t, err)
+	assert.Len(t, parsedItems, 0)
+}
+
+func TestWriteVersions(t *testing.T) {
+	table, _ := newTestTable(t)
+	table.Author = "author"
+
+	assert.NoError(t, table.Set(&models.Item{Key: "KEY", Value: "ONE", Serialization: "plain"}))
+	assert.NoError(t, table.Set(&models.Item{Key: "KEY", Value: "TWO", Serialization: "plain"}))
+
+	parsedItem, err := table.Get("KEY")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), parsedItem.Version)
+
+	entries, err := table.History().List("KEY")
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, "ONE", entries[0].Item.Value.Value)
+		assert.Equal(t, "author", entries[0].Author)
+		assert.False(t, entries[0].Timestamp.IsZero())
+	}
+
+	entry, err := table.History().Get("KEY", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "TWO", entry.Item.Value.Value)
+
+	assert.NoError(t, table.Delete("KEY"))
+	assert.NoError(t, table.Set(&models.Item{Key: "KEY", Value: "THREE", Serialization: "plain"}))
+	parsedItem, err = table.Get("KEY")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), parsedItem.Version)
+}
+
+func TestWriteUnversionedItem(t *testing.T) {
+	table, _ := newTestTable(t)
+
+	// Items written by older versions have no Version attribute.
+	assert.NoError(t, table.batchWrite([]*dynamodb.WriteRequest{{
+		PutRequest: &dynamodb.PutRequest{Item: table.itemToDynamoDB(&models.Item{Key: "KEY", Value: "OLD", Serialization: "plain"})},
+	}}))
+
+	assert.NoError(t, table.Set(&models.Item{Key: "KEY", Value: "NEW", Serialization: "plain"}))
+
+	entries, err := table.History().List("KEY")
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, int64(1), entries[0].Item.Version)
+		assert.Equal(t, "OLD", entries[0].Item.Value.Value)
+		assert.True(t, entries[0].Timestamp.IsZero())
+		assert.Equal(t, int64(2), entries[1].Item.Version)
+	}
+}
+
+func TestSetIfVersion(t *testing.T) {
+	table, _ := newTestTable(t)
+	item := testItems(1)[0]
+
+	assert.NoError(t, table.SetIfVersion(item, 0))
+	assert.Equal(t, int64(1), item.Version)
+
+	err := table.SetIfVersion(item, 0)
+	assert.Equal(t, &ConflictError{Keys: []string{"KEY_00"}}, err)
+
+	assert.NoError(t, table.SetIfVersion(item, 1))
+	assert.Equal(t, int64(2), item.Version)
+
+	err = table.SetIfVersion(item, 1)
+	assert.Equal(t, &ConflictError{Keys: []string{"KEY_00"}}, err)
+
+	parsedItem, err := table.Get("KEY_00")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), parsedItem.Version)
+}
+
+func TestSetIfVersionUnversionedItem(t *testing.T) {
+	table, _ := newTestTable(t)
+
+	// Items written by older versions have no Version attribute and count as
+	// version 1.
+	assert.NoError(t, table.batchWrite(putRequests(table, []*models.Item{{Key: "KEY", Value: "OLD", Serialization: "plain"}})))
+
+	item := &models.Item{Key: "KEY", Value: "NEW", Serialization: "plain"}
+	assert.Equal(t, &ConflictError{Keys: []string{"KEY"}}, table.SetIfVersion(item, 0))
+	assert.Equal(t, &ConflictError{Keys: []string{"KEY"}}, table.SetIfVersion(item, 2))
+	assert.NoError(t, table.SetIfVersion(item, 1))
+
+	parsedItem, err := table.Get("KEY")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), parsedItem.Version)
+	assert.Equal(t, "NEW", parsedItem.Value.Value)
+}
+
+func TestWriteIfVersion(t *testing.T) {
+	table, _ := newTestTable(t)
+	items := testItems(30)
+	expected := map[string]int64{}
+	for _, item := range items {
+		expected[item.Key] = 0
+	}
+
+	assert.NoError(t, table.WriteIfVersion(items, expected))
+	parsedItems, err := table.Read()
+	assert.NoError(t, err)
+	assert.Len(t, parsedItems, 30)
+
+	entries, err := table.History().List("KEY_29")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+// racingDynamoDB runs race before the transaction following the first after
+// transactions, as if another writer changed the table after it was read.
+type racingDynamoDB struct {
+	*fake.DynamoDB
+	race  func()
+	after int
+}
+
+func (svc *racingDynamoDB) TransactWriteItems(input *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+	if race := svc.race; race != nil {
+		if svc.after > 0 {
+			svc.after--
+		} else {
+			svc.race = nil
+			race()
+		}
+	}
+	return svc.DynamoDB.TransactWriteItems(input)
+}
+
+func TestWriteConcurrentWriter(t *testing.T) {
+	svc := &racingDynamoDB{DynamoDB: fake.NewDynamoDB()}
+	table := NewTable(svc, "TEST_TABLE")
+	assert.NoError(t, table.Create())
+	assert.NoError(t, table.Set(&models.Item{Key: "KEY", Value: "ONE", Serialization: "plain"}))
+
+	other := NewTable(svc.DynamoDB, "TEST_TABLE")
+	svc.race = func() {
+		assert.NoError(t, other.Set(&models.Item{Key: "KEY", Value: "OTHER", Serialization: "plain"}))
+	}
+	assert.NoError(t, table.Set(&models.Item{Key: "KEY", Value: "TWO", Serialization: "plain"}))
+
+	parsedItem, err := table.Get("KEY")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), parsedItem.Version)
+	assert.Equal(t, "TWO", parsedItem.Value.Value)
+
+	entries, err := table.History().List("KEY")
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 3) {
+		assert.Equal(t, "OTHER", entries[1].Item.Value.Value)
+		assert.Equal(t, "TWO", entries[2].Item.Value.Value)
+	}
+}
+
+func TestWriteIfVersionConcurrentWriter(t *testing.T) {
+	svc := &racingDynamoDB{DynamoDB: fake.NewDynamoDB()}
+	table := NewTable(svc, "TEST_TABLE")
+	assert.NoError(t, table.Create())
+	items := testItems(30)
+	assert.NoError(t, table.Write(items))
+
+	// KEY_27 changes after the versions were checked.
+	other := NewTable(svc.DynamoDB, "TEST_TABLE")
+	svc.race = func() {
+		assert.NoError(t, other.Set(&models.Item{Key: "KEY_27", Value: "OTHER", Serialization: "plain"}))
+	}
+	expected := map[string]int64{}
+	for _, item := range items {
+		expected[item.Key] = 1
+	}
+	err := table.WriteIfVersion(testItems(30), expected)
+	assert.Equal(t, &ConflictError{Keys: []string{"KEY_27"}}, err)
+
+	// No item of the transaction was written.
+	parsedItem, err := table.Get("KEY_00")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), parsedItem.Version)
+}
+
+func TestWriteIfVersionOverTransactionLimit(t *testing.T) {
+	svc := &racingDynamoDB{DynamoDB: fake.NewDynamoDB()}
+	table := NewTable(svc, "TEST_TABLE")
+	assert.NoError(t, table.Create())
+	items := testItems(transactWriteLimit + 50)
+	assert.NoError(t, table.Write(items))
+
+	expected := map[string]int64{}
+	for _, item := range items {
+		expected[item.Key] = 1
+	}
+	assert.NoError(t, table.WriteIfVersion(testItems(transactWriteLimit+50), expected))
+	parsedItem, err := table.Get("KEY_120")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), parsedItem.Version)
+
+	// KEY_120 changes after the first transaction was applied.
+	other := NewTable(svc.DynamoDB, "TEST_TABLE")
+	svc.race, svc.after = func() {
+		assert.NoError(t, other.Set(&models.Item{Key: "KEY_120", Value: "OTHER", Serialization: "plain"}))
+	}, 1
+	for key := range expected {
+		expected[key] = 2
+	}
+	err = table.WriteIfVersion(testItems(transactWriteLimit+50), expected)
+
+	writeErr, ok := err.(*WriteError)
+	if assert.True(t, ok) {
+		assert.Equal(t, itemKeys(testItems(transactWriteLimit + 50)[transactWriteLimit:]), writeErr.Keys)
+		assert.Equal(t, &ConflictError{Keys: []string{"KEY_120"}}, writeErr.Err)
+	}
+	parsedItem, err = table.Get("KEY_00")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), parsedItem.Version)
+	parsedItem, err = table.Get("KEY_149")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), parsedItem.Version)
+}
+
+// failingDynamoDB fails the transaction following the first after
+// transactions, or the history writes if failHistory is set.
+type failingDynamoDB struct {
+	*fake.DynamoDB
+	after       int
+	failHistory bool
+}
+
+func (svc *failingDynamoDB) TransactWriteItems(input *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+	if !svc.failHistory {
+		if svc.after == 0 {
+			return nil, fmt.Errorf("request failed")
+		}
+		svc.after--
+	}
+	return svc.DynamoDB.TransactWriteItems(input)
+}
+
+func (svc *failingDynamoDB) BatchWriteItem(input *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+	if svc.failHistory {
+		return nil, fmt.Errorf("request failed")
+	}
+	return svc.DynamoDB.BatchWriteItem(input)
+}
+
+func TestWriteReportsFailedTransaction(t *testing.T) {
+	svc := &failingDynamoDB{DynamoDB: fake.NewDynamoDB(), after: 1}
+	table := NewTable(svc, "TEST_TABLE")
+	assert.NoError(t, table.Create())
+
+	err := table.Write(testItems(60))
+
+	writeErr, ok := err.(*WriteError)
+	if assert.True(t, ok) {
+		assert.Equal(t, itemKeys(testItems(60)[batchWriteLimit:]), writeErr.Keys)
+		assert.EqualError(t, writeErr.Err, "request failed")
+	}
+	parsedItems, err := table.Read()
+	assert.NoError(t, err)
+	assert.Len(t, parsedItems, batchWriteLimit)
+}
+
+func TestWriteReportsFailedHistory(t *testing.T) {
+	svc := &failingDynamoDB{DynamoDB: fake.NewDynamoDB(), failHistory: true}
+	table := NewTable(svc, "TEST_TABLE")
+	assert.NoError(t, table.Create())
+
+	err := table.Write(testItems(2))
+
+	writeErr, ok := err.(*WriteError)
+	if assert.True(t, ok) {
+		assert.Equal(t, []string{"KEY_00", "KEY_01"}, writeErr.Keys)
+		assert.EqualError(t, writeErr.Err, "recording history: failed to write 2 item(s): KEY_00, KEY_01: request failed")
+	}
+}
+
+func TestWriteMetadata(t *testing.T) {
+	table, _ := newTestTable(t)
+	table.Author = "arn:aws:iam::000000000000:user/test"
+	table.Source = "store data.yml"
+
+	assert.NoError(t, table.Write(testItems(1)))
+
+	parsedItem, err := table.Get("KEY_00")
+	assert.NoError(t, err)
+	assert.Equal(t, "arn:aws:iam::000000000000:user/test", parsedItem.UpdatedBy)
+	assert.Equal(t, "store data.yml", parsedItem.Source)
+	assert.WithinDuration(t, time.Now(), parsedItem.UpdatedAt, time.Minute)
+}
+
+func TestNamespace(t *testing.T) {
+	table, _ := newTestTable(t)
+	prod := NewTable(table.svc, "TEST_TABLE")
+	prod.Namespace = "prod/api"
+
+	assert.NoError(t, table.Write(testItems(2)))
+	assert.NoError(t, prod.Set(&models.Item{Key: "KEY_00", Value: "PROD", Serialization: "plain"}))
+
+	parsedItems, err := prod.Read()
+	assert.NoError(t, err)
+	assert.Len(t, parsedItems, 1)
+	assert.Equal(t, "KEY_00", parsedItems[0].Key)
+	assert.Equal(t, "PROD", parsedItems[0].Value.Value)
+
+	parsedItems, err = table.Read()
+	assert.NoError(t, err)
+	assert.Len(t, parsedItems, 2)
+	assert.Equal(t, "VALUE_00", parsedItems[0].Value.Value)
+
+	entries, err := prod.History().List("KEY_00")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "KEY_00", entries[0].Item.Key)
+
+	assert.NoError(t, prod.Delete("KEY_00"))
+	_, err = table.Get("KEY_00")
+	assert.NoError(t, err)
+}
+
+func TestCreateWithOptions(t *testing.T) {
+	svc := fake.NewDynamoDB()
+	table := NewTable(svc, "TEST_TABLE")
+
+	err := table.CreateWithOptions(&CreateOptions{
+		BillingMode:         dynamodb.BillingModeProvisioned,
+		ReadCapacity:        5,
+		WriteCapacity:       3,
+		KMSKey:              "alias/table",
+		Tags:                map[string]string{"team": "platform"},
+		PointInTimeRecovery: true,
+		DeletionProtection:  true,
+	})
+	assert.NoError(t, err)
+
+	for _, name := range []string{"TEST_TABLE", "TEST_TABLE" + HistorySuffix} {
+		resp, err := svc.DescribeTable(&dynamodb.DescribeTableInput{TableName: aws.String(name)})
+		assert.NoError(t, err)
+		assert.Equal(t, dynamodb.BillingModeProvisioned, *resp.Table.BillingModeSummary.BillingMode)
+		assert.Equal(t, int64(5), *resp.Table.ProvisionedThroughput.ReadCapacityUnits)
+		assert.Equal(t, "alias/table", *resp.Table.SSEDescription.KMSMasterKeyArn)
+		assert.True(t, *resp.Table.DeletionProtectionEnabled)
+
+		backups, err := svc.DescribeContinuousBackups(&dynamodb.DescribeContinuousBackupsInput{TableName: aws.String(name)})
+		assert.NoError(t, err)
+		assert.Equal(t, dynamodb.PointInTimeRecoveryStatusEnabled, *backups.ContinuousBackupsDescription.PointInTimeRecoveryDescription.PointInTimeRecoveryStatus)
+	}
+}
+
+func TestCreateDefaultsToOnDemand(t *testing.T) {
+	table, svc := newTestTable(t)
+
+	resp, err := svc.DescribeTable(&dynamodb.DescribeTableInput{TableName: table.Name})
+	assert.NoError(t, err)
+	assert.Equal(t, dynamodb.BillingModePayPerRequest, *resp.Table.BillingModeSummary.BillingMode)
+	assert.Nil(t, resp.Table.ProvisionedThroughput)
+
+	err = table.CreateWithOptions(&CreateOptions{BillingMode: dynamodb.BillingModePayPerRequest, ReadCapacity: 5})
+	assert.EqualError(t, err, "Capacity can only be set with PROVISIONED billing")
+}
+
+// queryCounter counts the Query calls made to a DynamoDB fake.
+type queryCounter struct {
+	*fake.DynamoDB
+	queries int
+}
+
+func (svc *queryCounter) Query(input *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+	svc.queries++
+	return svc.DynamoDB.Query(input)
+}
+
+func TestWriteContinuesVersionsOfDeletedKeys(t *testing.T) {
+	svc := &queryCounter{DynamoDB: fake.NewDynamoDB()}
+	table := NewTable(svc, "TEST_TABLE")
+	assert.NoError(t, table.Create())
+
+	assert.NoError(t, table.Write(testItems(30)))
+	assert.Equal(t, 0, svc.queries)
+
+	assert.NoError(t, table.Delete("KEY_00"))
+	assert.NoError(t, table.Write(testItems(1)))
+	assert.Equal(t, 1, svc.queries)
+
+	parsedItem, err := table.Get("KEY_00")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), parsedItem.Version)
+	entries, err := table.History().List("KEY_00")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
+
+func TestMigrate(t *testing.T) {
+	svc := fake.NewDynamoDB()
+	table := NewTable(svc, "TEST_TABLE")
+
+	// Tables created by older versions have no history table and no tags.
+	_, err := svc.CreateTable(&dynamodb.CreateTableInput{
+		TableName:            table.Name,
+		KeySchema:            []*dynamodb.KeySchemaElement{{AttributeName: aws.String("Key"), KeyType: aws.String("HASH")}},
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{{AttributeName: aws.String("Key"), AttributeType: aws.String("S")}},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, table.batchWrite([]*dynamodb.WriteRequest{
+		{PutRequest: &dynamodb.PutRequest{Item: table.itemToDynamoDB(&models.Item{Key: "KEY", Value: "OLD", Serialization: "plain"})}},
+		{PutRequest: &dynamodb.PutRequest{Item: table.itemToDynamoDB(&models.Item{Key: "prod::KEY", Value: "PROD", Serialization: "plain"})}},
+	}))
+
+	names, err := List(svc)
+	assert.NoError(t, err)
+	assert.Empty(t, names)
+
+	applied, err := table.Migrate()
+	assert.NoError(t, err)
+	assert.Len(t, applied, SchemaVersion)
+
+	description, err := table.Describe()
+	assert.NoError(t, err)
+	assert.Equal(t, SchemaVersion, description.SchemaVersion)
+	assert.True(t, description.HasHistory)
+
+	prod := NewTable(svc, "TEST_TABLE")
+	prod.Namespace = "prod"
+	parsedItem, err := prod.Get("KEY")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), parsedItem.Version)
+	entries, err := prod.History().List("KEY")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	keys, err := table.SeparatorKeys()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"prod::KEY"}, keys)
+
+	applied, err = table.Migrate()
+	assert.NoError(t, err)
+	assert.Empty(t, applied)
+
+	names, err = List(svc)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"TEST_TABLE"}, names)
+}
+
+func TestMigrateSkipsItemsWrittenMeanwhile(t *testing.T) {
+	svc := &racingDynamoDB{DynamoDB: fake.NewDynamoDB()}
+	table := NewTable(svc, "TEST_TABLE")
+	_, err := svc.CreateTable(&dynamodb.CreateTableInput{
+		TableName:            table.Name,
+		KeySchema:            []*dynamodb.KeySchemaElement{{AttributeName: aws.String("Key"), KeyType: aws.String("HASH")}},
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{{AttributeName: aws.String("Key"), AttributeType: aws.String("S")}},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, table.batchWrite(putRequests(table, testItems(3))))
+
+	// KEY_01 is written by a newer dynamokv after the migration read it.
+	svc.race = func() {
+		other := NewTable(svc.DynamoDB, "TEST_TABLE")
+		assert.NoError(t, other.Set(&models.Item{Key: "KEY_01", Value: "OTHER", Serialization: "plain"}))
+	}
+	_, err = table.Migrate()
+	assert.NoError(t, err)
+
+	parsedItem, err := table.Get("KEY_01")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), parsedItem.Version)
+	assert.Equal(t, "OTHER", parsedItem.Value.Value)
+	entries, err := table.History().List("KEY_01")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	for _, key := range []string{"KEY_00", "KEY_02"} {
+		parsedItem, err := table.Get(key)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), parsedItem.Version)
+		entries, err := table.History().List(key)
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1)
+	}
+}